@@ -0,0 +1,480 @@
+// Package apu emulates the audio processing unit and its four channels,
+// mixed down to stereo samples
+package apu
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+
+	"github.com/hmatheisen/gameboy/cpu"
+)
+
+const (
+	// Clock driving the channel timers, in T-cycles per second
+	apuClock = 4 * cpu.CyclesPerSecond
+
+	// The frame sequencer steps at 512 Hz
+	frameSequencerCycles = cpu.CyclesPerSecond / 512
+
+	DefaultSampleRate = 48000
+)
+
+// Bits reading as 1 in NR10 to NR52 and the unused registers up to 0xFF2F
+var apuReadMasks = [0x20]uint8{
+	0x80, 0x3F, 0x00, 0xFF, 0xBF,
+	0xFF, 0x3F, 0x00, 0xFF, 0xBF,
+	0x7F, 0xFF, 0x9F, 0xFF, 0xBF,
+	0xFF, 0xFF, 0x00, 0x00, 0xBF,
+	0x00, 0x00, 0x70,
+	0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF,
+}
+
+// Channel identifies one of the 4 sound channels, in NR51/NR52 bit order
+type Channel int
+
+const (
+	ChannelSquare1 Channel = iota
+	ChannelSquare2
+	ChannelWave
+	ChannelNoise
+)
+
+var channelNames = [4]string{"square1", "square2", "wave", "noise"}
+
+func (c Channel) String() string {
+	return channelNames[c]
+}
+
+func ParseChannel(name string) (Channel, error) {
+	i := slices.Index(channelNames[:], strings.ToLower(name))
+	if i < 0 {
+		return 0, fmt.Errorf("unknown channel %q", name)
+	}
+	return Channel(i), nil
+}
+
+// APU emulates the two square channels, the wave channel and the noise
+// channel mapped at 0xFF10-0xFF3F, and mixes them into a stereo stream of
+// float32 samples interleaved as left, right
+type APU struct {
+	// Output rate in Hz, 0 disables sample output
+	sampleRate int
+
+	regs  [0x20]uint8
+	power bool
+
+	square1 square
+	sweep   sweep
+	square2 square
+	wave    wave
+	noise   noise
+
+	// Next frame sequencer step, and M-cycles until it runs
+	step      int
+	stepTimer int
+
+	sampleClock int
+	samples     []float32
+
+	// Channels left out of the mix, either muted or not soloed while
+	// another channel is
+	muted [4]bool
+	solo  [4]bool
+
+	// Each channel on its own, panned and scaled like in the mix but
+	// regardless of muting
+	stemsEnabled bool
+	stems        [4][]float32
+
+	// High-pass filters removing the DC offset of the DACs, as the
+	// capacitors on the audio output do
+	capacitor     [2]float64
+	stemCapacitor [4][2]float64
+	charge        float64
+}
+
+func New(sampleRate int) *APU {
+	apu := new(APU)
+
+	apu.SetSampleRate(sampleRate)
+	apu.stepTimer = frameSequencerCycles
+
+	// State left by the DMG boot ROM
+	apu.Write(0xFF26, 0x80)
+	apu.Write(0xFF24, 0x77)
+	apu.Write(0xFF25, 0xF3)
+	apu.Write(0xFF11, 0x80)
+	apu.Write(0xFF12, 0xF3)
+
+	return apu
+}
+
+func (a *APU) SampleRate() int {
+	return a.sampleRate
+}
+
+// SetSampleRate changes the output rate in Hz, 0 disables sample output
+func (a *APU) SetSampleRate(rate int) {
+	a.sampleRate = rate
+	a.charge = math.Pow(0.999958, float64(apuClock)/float64(max(rate, 1)))
+}
+
+// DrainSamples returns the samples produced since the last call. Once a
+// second of audio is buffered newer samples are dropped
+func (a *APU) DrainSamples() []float32 {
+	samples := a.samples
+	a.samples = nil
+	return samples
+}
+
+func (a *APU) SetMuted(channel Channel, muted bool) {
+	a.muted[channel] = muted
+}
+
+func (a *APU) Muted(channel Channel) bool {
+	return a.muted[channel]
+}
+
+// SetSolo adds or removes a channel from the soloed channels, while any
+// channel is soloed the others are left out of the mix
+func (a *APU) SetSolo(channel Channel, solo bool) {
+	a.solo[channel] = solo
+}
+
+func (a *APU) Solo(channel Channel) bool {
+	return a.solo[channel]
+}
+
+func (a *APU) audible(channel int) bool {
+	if a.muted[channel] {
+		return false
+	}
+	soloed := a.solo[0] || a.solo[1] || a.solo[2] || a.solo[3]
+	return !soloed || a.solo[channel]
+}
+
+// EnableStems turns the output of each channel on its own on or off
+func (a *APU) EnableStems(enabled bool) {
+	a.stemsEnabled = enabled
+	a.stems = [4][]float32{}
+}
+
+// DrainStems returns the samples of each channel produced since the last
+// call, in the same format as DrainSamples
+func (a *APU) DrainStems() [4][]float32 {
+	stems := a.stems
+	a.stems = [4][]float32{}
+	return stems
+}
+
+// Tick advances the APU by one machine cycle
+func (a *APU) Tick() {
+	if a.power {
+		a.square1.tick(4)
+		a.square2.tick(4)
+		a.wave.tick(4)
+		a.noise.tick(4)
+
+		a.stepTimer--
+		if a.stepTimer == 0 {
+			a.stepTimer = frameSequencerCycles
+			a.stepFrameSequencer()
+		}
+	}
+
+	if a.sampleRate <= 0 {
+		return
+	}
+	a.sampleClock += a.sampleRate * 4
+	for a.sampleClock >= apuClock {
+		a.sampleClock -= apuClock
+		a.mix()
+	}
+}
+
+// Lengths are clocked on even steps, the sweep on steps 2 and 6 and the
+// envelopes on step 7
+func (a *APU) stepFrameSequencer() {
+	if a.step&1 == 0 {
+		a.square1.lengthCounter.clock(&a.square1.on)
+		a.square2.lengthCounter.clock(&a.square2.on)
+		a.wave.lengthCounter.clock(&a.wave.on)
+		a.noise.lengthCounter.clock(&a.noise.on)
+	}
+	if a.step == 2 || a.step == 6 {
+		a.clockSweep()
+	}
+	if a.step == 7 {
+		a.square1.envelope.clock()
+		a.square2.envelope.clock()
+		a.noise.envelope.clock()
+	}
+
+	a.step = (a.step + 1) & 7
+}
+
+// Computes the next sweep frequency, disabling the channel on overflow
+func (a *APU) sweepFrequency() uint16 {
+	s := &a.sweep
+
+	delta := s.shadow >> s.shift
+	freq := s.shadow + delta
+	if s.negate {
+		freq = s.shadow - delta
+		s.negated = true
+	}
+
+	if freq > 2047 {
+		a.square1.on = false
+	}
+	return freq
+}
+
+func (a *APU) clockSweep() {
+	s := &a.sweep
+
+	s.timer--
+	if s.timer > 0 {
+		return
+	}
+	s.timer = s.period
+	if s.timer == 0 {
+		s.timer = 8
+	}
+
+	if !s.enabled || s.period == 0 {
+		return
+	}
+
+	freq := a.sweepFrequency()
+	if freq <= 2047 && s.shift != 0 {
+		s.shadow = freq
+		a.square1.freq = freq
+		a.regs[0x03] = uint8(freq)
+		a.regs[0x04] = a.regs[0x04]&^0x07 | uint8(freq>>8)
+		// Checked again for overflow, without being written back
+		a.sweepFrequency()
+	}
+}
+
+func (a *APU) triggerSweep() {
+	s := &a.sweep
+
+	s.shadow = a.square1.freq
+	s.timer = s.period
+	if s.timer == 0 {
+		s.timer = 8
+	}
+	s.negated = false
+	s.enabled = s.period != 0 || s.shift != 0
+	if s.shift != 0 {
+		a.sweepFrequency()
+	}
+}
+
+// Converts the 4-bit output of a channel DAC to the -1..1 range, DACs which
+// are off output nothing
+func dac(on bool, value uint8) float64 {
+	if !on {
+		return 0
+	}
+	return 1 - float64(value)/7.5
+}
+
+func (a *APU) mix() {
+	// Buffers are capped to a second of audio
+	mix := len(a.samples) < 2*a.sampleRate
+	stems := a.stemsEnabled && len(a.stems[0]) < 2*a.sampleRate
+	if !mix && !stems {
+		return
+	}
+
+	outputs := [4]float64{
+		dac(a.square1.dac, a.square1.output()),
+		dac(a.square2.dac, a.square2.output()),
+		dac(a.wave.dac, a.wave.output()),
+		dac(a.noise.dac, a.noise.output()),
+	}
+
+	nr50 := a.regs[0x14]
+	nr51 := a.regs[0x15]
+	// Right is on the low bits of NR50 and NR51, left on the high bits
+	for side, shift := range []uint{4, 0} {
+		volume := float64(nr50>>shift&0x07+1) / 8 / 4
+
+		var sample float64
+		for i, output := range outputs {
+			var value float64
+			if a.power && nr51>>(shift+uint(i))&1 != 0 {
+				value = output * volume
+			}
+			if a.audible(i) {
+				sample += value
+			}
+			if stems {
+				a.stems[i] = append(a.stems[i], a.highPass(&a.stemCapacitor[i][side], value))
+			}
+		}
+
+		if mix {
+			a.samples = append(a.samples, a.highPass(&a.capacitor[side], sample))
+		}
+	}
+}
+
+func (a *APU) highPass(capacitor *float64, sample float64) float32 {
+	out := sample - *capacitor
+	*capacitor = sample - out*a.charge
+	return float32(out)
+}
+
+func (a *APU) Read(addr uint16) uint8 {
+	if addr >= 0xFF30 {
+		return a.wave.ram[addr-0xFF30]
+	}
+
+	if addr == 0xFF26 {
+		value := uint8(0x70)
+		if a.power {
+			value |= 0x80
+		}
+		for i, on := range []bool{a.square1.on, a.square2.on, a.wave.on, a.noise.on} {
+			if on {
+				value |= 1 << i
+			}
+		}
+		return value
+	}
+
+	reg := addr - 0xFF10
+	return a.regs[reg] | apuReadMasks[reg]
+}
+
+func (a *APU) Write(addr uint16, value uint8) {
+	if addr >= 0xFF30 {
+		a.wave.ram[addr-0xFF30] = value
+		return
+	}
+
+	reg := addr - 0xFF10
+	if addr == 0xFF26 {
+		a.setPower(value&0x80 != 0)
+		return
+	}
+
+	// While powered off the registers are read only, except for the length
+	// counters on DMG
+	if !a.power {
+		switch reg {
+		case 0x01, 0x06, 0x10:
+			value &= 0x3F
+		case 0x0B:
+		default:
+			return
+		}
+	}
+
+	a.regs[reg] = value
+	// The next step clocks the lengths if it is even
+	oddStep := a.step&1 == 1
+
+	switch reg {
+	case 0x00:
+		a.sweep.period = value >> 4 & 0x07
+		a.sweep.negate = value&0x08 != 0
+		a.sweep.shift = value & 0x07
+		if a.sweep.negated && !a.sweep.negate {
+			a.square1.on = false
+		}
+	case 0x01:
+		a.square1.duty = value >> 6
+		a.square1.counter = 64 - int(value&0x3F)
+	case 0x02:
+		a.square1.envelope.write(value)
+		a.square1.dac = value&0xF8 != 0
+		a.square1.on = a.square1.on && a.square1.dac
+	case 0x03:
+		a.square1.freq = a.square1.freq&0x700 | uint16(value)
+	case 0x04:
+		a.square1.freq = a.square1.freq&0xFF | uint16(value&0x07)<<8
+		a.square1.lengthCounter.write(value, 64, &a.square1.on, oddStep)
+		if value&0x80 != 0 {
+			a.square1.trigger()
+			a.triggerSweep()
+		}
+	case 0x06:
+		a.square2.duty = value >> 6
+		a.square2.counter = 64 - int(value&0x3F)
+	case 0x07:
+		a.square2.envelope.write(value)
+		a.square2.dac = value&0xF8 != 0
+		a.square2.on = a.square2.on && a.square2.dac
+	case 0x08:
+		a.square2.freq = a.square2.freq&0x700 | uint16(value)
+	case 0x09:
+		a.square2.freq = a.square2.freq&0xFF | uint16(value&0x07)<<8
+		a.square2.lengthCounter.write(value, 64, &a.square2.on, oddStep)
+		if value&0x80 != 0 {
+			a.square2.trigger()
+		}
+	case 0x0A:
+		a.wave.dac = value&0x80 != 0
+		a.wave.on = a.wave.on && a.wave.dac
+	case 0x0B:
+		a.wave.counter = 256 - int(value)
+	case 0x0C:
+		a.wave.volume = value >> 5 & 0x03
+	case 0x0D:
+		a.wave.freq = a.wave.freq&0x700 | uint16(value)
+	case 0x0E:
+		a.wave.freq = a.wave.freq&0xFF | uint16(value&0x07)<<8
+		a.wave.lengthCounter.write(value, 256, &a.wave.on, oddStep)
+		if value&0x80 != 0 {
+			a.wave.trigger()
+		}
+	case 0x10:
+		a.noise.counter = 64 - int(value&0x3F)
+	case 0x11:
+		a.noise.envelope.write(value)
+		a.noise.dac = value&0xF8 != 0
+		a.noise.on = a.noise.on && a.noise.dac
+	case 0x12:
+		a.noise.shift = value >> 4
+		a.noise.narrow = value&0x08 != 0
+		a.noise.divisor = value & 0x07
+	case 0x13:
+		a.noise.lengthCounter.write(value, 64, &a.noise.on, oddStep)
+		if value&0x80 != 0 {
+			a.noise.trigger()
+		}
+	}
+}
+
+// Powering off clears every register but the wave RAM, powering on restarts
+// the frame sequencer
+func (a *APU) setPower(on bool) {
+	if on && !a.power {
+		a.step = 0
+		a.stepTimer = frameSequencerCycles
+	}
+	if !on && a.power {
+		ram := a.wave.ram
+		// Length counters survive on DMG
+		counters := [4]int{a.square1.counter, a.square2.counter, a.wave.counter, a.noise.counter}
+
+		a.regs = [0x20]uint8{}
+		a.square1 = square{}
+		a.sweep = sweep{}
+		a.square2 = square{}
+		a.wave = wave{ram: ram}
+		a.noise = noise{}
+
+		a.square1.counter = counters[0]
+		a.square2.counter = counters[1]
+		a.wave.counter = counters[2]
+		a.noise.counter = counters[3]
+	}
+	a.power = on
+}
@@ -0,0 +1,227 @@
+package apu
+
+// Waveforms for the 4 square duty cycles, 12.5%, 25%, 50% and 75%, read
+// from the most significant bit
+var dutyTable = [4]uint8{0x01, 0x81, 0x87, 0x7E}
+
+// Noise frequency divisors for each NR43 divisor code
+var noiseDivisors = [8]int{8, 16, 32, 48, 64, 80, 96, 112}
+
+// Length counters silence their channel once they count down to 0, when
+// enabled by bit 6 of NRx4
+type lengthCounter struct {
+	counter int
+	enabled bool
+}
+
+func (l *lengthCounter) clock(on *bool) {
+	if l.enabled && l.counter > 0 {
+		l.counter--
+		if l.counter == 0 {
+			*on = false
+		}
+	}
+}
+
+// Handles the length part of a write to NRx4. Enabling the counter while
+// the next frame sequencer step does not clock lengths clocks it once more,
+// which also applies to a counter reloaded by the trigger
+func (l *lengthCounter) write(value uint8, max int, on *bool, oddStep bool) {
+	wasEnabled := l.enabled
+	l.enabled = value&0x40 != 0
+	trigger := value&0x80 != 0
+
+	if oddStep && !wasEnabled && l.enabled && l.counter > 0 {
+		l.counter--
+		if l.counter == 0 && !trigger {
+			*on = false
+		}
+	}
+
+	if trigger && l.counter == 0 {
+		l.counter = max
+		if oddStep && l.enabled {
+			l.counter--
+		}
+	}
+}
+
+// Volume envelopes of the square and noise channels, from NRx2
+type envelope struct {
+	initial uint8
+	up      bool
+	period  uint8
+
+	volume uint8
+	timer  uint8
+}
+
+func (e *envelope) write(value uint8) {
+	e.initial = value >> 4
+	e.up = value&0x08 != 0
+	e.period = value & 0x07
+}
+
+func (e *envelope) trigger() {
+	e.volume = e.initial
+	e.timer = e.period
+}
+
+func (e *envelope) clock() {
+	if e.period == 0 {
+		return
+	}
+
+	e.timer--
+	if e.timer > 0 {
+		return
+	}
+	e.timer = e.period
+
+	if e.up && e.volume < 15 {
+		e.volume++
+	} else if !e.up && e.volume > 0 {
+		e.volume--
+	}
+}
+
+// The frequency sweep of square channel 1, from NR10
+type sweep struct {
+	period uint8
+	negate bool
+	shift  uint8
+
+	enabled bool
+	shadow  uint16
+	timer   uint8
+	// Set once a frequency got computed in negate mode, clearing the negate
+	// bit afterwards disables the channel
+	negated bool
+}
+
+type square struct {
+	on  bool
+	dac bool
+	lengthCounter
+	envelope
+
+	duty    uint8
+	dutyPos uint8
+	freq    uint16
+	timer   int
+}
+
+func (s *square) period() int {
+	return (2048 - int(s.freq)) * 4
+}
+
+func (s *square) tick(cycles int) {
+	s.timer -= cycles
+	for s.timer <= 0 {
+		s.timer += s.period()
+		s.dutyPos = (s.dutyPos + 1) & 7
+	}
+}
+
+func (s *square) trigger() {
+	s.on = s.dac
+	s.timer = s.period()
+	s.envelope.trigger()
+}
+
+func (s *square) output() uint8 {
+	if !s.on || dutyTable[s.duty]>>(7-s.dutyPos)&1 == 0 {
+		return 0
+	}
+	return s.volume
+}
+
+type wave struct {
+	on  bool
+	dac bool
+	lengthCounter
+
+	// Volume code from NR32, 0 mutes, 1 to 3 shift the samples right by 0
+	// to 2 bits
+	volume   uint8
+	freq     uint16
+	timer    int
+	position uint8
+	sample   uint8
+	ram      [16]uint8
+}
+
+func (w *wave) period() int {
+	return (2048 - int(w.freq)) * 2
+}
+
+func (w *wave) tick(cycles int) {
+	w.timer -= cycles
+	for w.timer <= 0 {
+		w.timer += w.period()
+		w.position = (w.position + 1) & 31
+		w.sample = w.ram[w.position/2]
+		if w.position&1 == 0 {
+			w.sample >>= 4
+		}
+		w.sample &= 0x0F
+	}
+}
+
+func (w *wave) trigger() {
+	w.on = w.dac
+	w.position = 0
+	// The first sample is read a little after the trigger
+	w.timer = w.period() + 6
+}
+
+func (w *wave) output() uint8 {
+	if !w.on || w.volume == 0 {
+		return 0
+	}
+	return w.sample >> (w.volume - 1)
+}
+
+type noise struct {
+	on  bool
+	dac bool
+	lengthCounter
+	envelope
+
+	shift   uint8
+	narrow  bool // 7-bit LFSR
+	divisor uint8
+	lfsr    uint16
+	timer   int
+}
+
+func (n *noise) period() int {
+	return noiseDivisors[n.divisor] << n.shift
+}
+
+func (n *noise) tick(cycles int) {
+	n.timer -= cycles
+	for n.timer <= 0 {
+		n.timer += n.period()
+
+		bit := (n.lfsr ^ n.lfsr>>1) & 1
+		n.lfsr = n.lfsr>>1 | bit<<14
+		if n.narrow {
+			n.lfsr = n.lfsr&^(1<<6) | bit<<6
+		}
+	}
+}
+
+func (n *noise) trigger() {
+	n.on = n.dac
+	n.lfsr = 0x7FFF
+	n.timer = n.period()
+	n.envelope.trigger()
+}
+
+func (n *noise) output() uint8 {
+	if !n.on || n.lfsr&1 != 0 {
+		return 0
+	}
+	return n.volume
+}
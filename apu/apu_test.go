@@ -0,0 +1,316 @@
+package apu
+
+import "testing"
+
+func newTestAPU() *APU {
+	apu := New(DefaultSampleRate)
+	apu.Write(0xFF26, 0x00)
+	apu.Write(0xFF26, 0x80)
+	apu.Write(0xFF24, 0x77)
+	apu.Write(0xFF25, 0xFF)
+
+	return apu
+}
+
+func tickAPU(a *APU, cycles int) {
+	for i := 0; i < cycles; i++ {
+		a.Tick()
+	}
+}
+
+// Runs the frame sequencer until it is about to run the given step
+func runToStep(a *APU, step int) {
+	for a.step != step {
+		tickAPU(a, a.stepTimer)
+	}
+}
+
+func TestAPUReadMasks(t *testing.T) {
+	a := New(0)
+	a.Write(0xFF26, 0x00)
+
+	for addr := uint16(0xFF10); addr < 0xFF30; addr++ {
+		want := apuReadMasks[addr-0xFF10]
+		if got := a.Read(addr); got != want {
+			t.Errorf("%04x: want: %02x; got %02x", addr, want, got)
+		}
+	}
+}
+
+func TestAPUPowerOff(t *testing.T) {
+	a := newTestAPU()
+	a.Write(0xFF30, 0x12)
+	a.Write(0xFF17, 0xF0)
+	a.Write(0xFF19, 0x80)
+	if a.Read(0xFF26) != 0xF2 {
+		t.Fatalf("want: NR52 = f2; got %02x", a.Read(0xFF26))
+	}
+
+	a.Write(0xFF26, 0x00)
+	if a.Read(0xFF26) != 0x70 || a.Read(0xFF17) != 0x00 {
+		t.Errorf("want: channels and registers cleared; got NR52 = %02x, NR22 = %02x", a.Read(0xFF26), a.Read(0xFF17))
+	}
+
+	a.Write(0xFF24, 0x77)
+	if a.Read(0xFF24) != 0x00 {
+		t.Errorf("want: writes ignored while off; got NR50 = %02x", a.Read(0xFF24))
+	}
+	if a.Read(0xFF30) != 0x12 {
+		t.Errorf("want: wave RAM kept; got %02x", a.Read(0xFF30))
+	}
+}
+
+func TestAPULength(t *testing.T) {
+	a := newTestAPU()
+	runToStep(a, 1)
+
+	// A length of 2 on the square 2 channel
+	a.Write(0xFF16, 0x3E)
+	a.Write(0xFF17, 0xF0)
+	a.Write(0xFF19, 0x80)
+	runToStep(a, 4)
+	if a.Read(0xFF26)&0x02 == 0 {
+		t.Fatalf("want: length ignored while disabled")
+	}
+
+	a.Write(0xFF19, 0x40)
+	runToStep(a, 5)
+	if a.Read(0xFF26)&0x02 == 0 {
+		t.Fatalf("want: channel on after 1 length clock")
+	}
+	runToStep(a, 7)
+	if a.Read(0xFF26)&0x02 != 0 {
+		t.Errorf("want: channel off after 2 length clocks")
+	}
+}
+
+func TestAPULengthExtraClock(t *testing.T) {
+	a := newTestAPU()
+	// The next step does not clock lengths
+	runToStep(a, 1)
+
+	a.Write(0xFF16, 0x3F)
+	a.Write(0xFF17, 0xF0)
+	a.Write(0xFF19, 0x80)
+	a.Write(0xFF19, 0x40)
+	if a.Read(0xFF26)&0x02 != 0 {
+		t.Errorf("want: channel off after enabling the length on an odd step")
+	}
+
+	// Triggering with a zero length loads 63 instead of 64
+	a.Write(0xFF19, 0xC0)
+	if a.square2.counter != 63 {
+		t.Errorf("want: length 63; got %d", a.square2.counter)
+	}
+}
+
+func TestAPUEnvelope(t *testing.T) {
+	a := newTestAPU()
+	// Volume 2, increasing every step 7
+	a.Write(0xFF17, 0x29)
+	a.Write(0xFF19, 0x80)
+
+	for i := 0; i < 3; i++ {
+		runToStep(a, 7)
+		runToStep(a, 0)
+	}
+	if a.square2.volume != 5 {
+		t.Errorf("want: volume 5; got %d", a.square2.volume)
+	}
+}
+
+func TestAPUDAC(t *testing.T) {
+	a := newTestAPU()
+	a.Write(0xFF1A, 0x00)
+	a.Write(0xFF1E, 0x80)
+	if a.Read(0xFF26)&0x04 != 0 {
+		t.Errorf("want: wave channel off with its DAC off")
+	}
+
+	a.Write(0xFF21, 0xF0)
+	a.Write(0xFF23, 0x80)
+	a.Write(0xFF21, 0x07)
+	if a.Read(0xFF26)&0x08 != 0 {
+		t.Errorf("want: noise channel off once its DAC is off")
+	}
+}
+
+func TestAPUSweep(t *testing.T) {
+	a := newTestAPU()
+	a.Write(0xFF12, 0xF0)
+
+	// An overflow computed when triggering disables the channel at once
+	a.Write(0xFF10, 0x11)
+	a.Write(0xFF13, 0xFF)
+	a.Write(0xFF14, 0x87)
+	if a.Read(0xFF26)&0x01 != 0 {
+		t.Errorf("overflow: want: channel off")
+	}
+
+	// 0x400 + 0x400>>2 every sweep clock
+	a.Write(0xFF10, 0x12)
+	a.Write(0xFF13, 0x00)
+	a.Write(0xFF14, 0x84)
+	runToStep(a, 3)
+	runToStep(a, 3)
+	if a.square1.freq != 0x500 {
+		t.Errorf("sweep: want: frequency 500; got %03x", a.square1.freq)
+	}
+
+	// Leaving negate mode after a negated computation disables the channel
+	a.Write(0xFF10, 0x1A)
+	a.Write(0xFF14, 0x84)
+	a.Write(0xFF10, 0x12)
+	if a.Read(0xFF26)&0x01 != 0 {
+		t.Errorf("negate: want: channel off")
+	}
+}
+
+func TestAPUNoise(t *testing.T) {
+	a := newTestAPU()
+	a.Write(0xFF21, 0xF0)
+	a.Write(0xFF23, 0x80)
+
+	// The LFSR shifts in the XOR of its 2 lowest bits
+	a.noise.tick(a.noise.timer)
+	if a.noise.lfsr != 0x3FFF {
+		t.Errorf("want: lfsr = 3fff; got %04x", a.noise.lfsr)
+	}
+
+	a.Write(0xFF22, 0x08)
+	a.noise.lfsr = 0x0001
+	a.noise.tick(a.noise.timer)
+	if a.noise.lfsr != 0x4040 {
+		t.Errorf("7-bit: want: lfsr = 4040; got %04x", a.noise.lfsr)
+	}
+}
+
+func TestAPUSamples(t *testing.T) {
+	a := newTestAPU()
+	// 50% duty square on the right side only
+	a.Write(0xFF25, 0x02)
+	a.Write(0xFF16, 0x80)
+	a.Write(0xFF17, 0xF0)
+	a.Write(0xFF18, 0x00)
+	a.Write(0xFF19, 0x87)
+
+	tickAPU(a, 17556)
+	samples := a.DrainSamples()
+	if len(samples) != 2*803 {
+		t.Fatalf("want: 803 stereo samples per frame; got %d values", len(samples))
+	}
+
+	var left, right float32
+	for i := 0; i < len(samples); i += 2 {
+		left = max(left, samples[i])
+		right = max(right, samples[i+1])
+	}
+	if left != 0 || right == 0 {
+		t.Errorf("want: sound on the right only; got peaks left %f, right %f", left, right)
+	}
+
+	if len(a.DrainSamples()) != 0 {
+		t.Errorf("want: samples drained")
+	}
+}
+
+// Plays noise on the left and square 2 on the right
+func newTestMix() *APU {
+	a := newTestAPU()
+	a.Write(0xFF25, 0x82)
+	a.Write(0xFF17, 0xF0)
+	a.Write(0xFF19, 0x87)
+	a.Write(0xFF21, 0xF0)
+	a.Write(0xFF23, 0x80)
+
+	return a
+}
+
+// Peak levels of each side
+func peaks(samples []float32) (left, right float32) {
+	for i := 0; i < len(samples); i += 2 {
+		left = max(left, samples[i])
+		right = max(right, samples[i+1])
+	}
+	return left, right
+}
+
+func TestAPUMuteSolo(t *testing.T) {
+	tests := []struct {
+		name        string
+		setup       func(a *APU)
+		left, right bool
+	}{
+		{"all", func(a *APU) {}, true, true},
+		{"mute square 2", func(a *APU) { a.SetMuted(ChannelSquare2, true) }, true, false},
+		{"solo square 2", func(a *APU) { a.SetSolo(ChannelSquare2, true) }, false, true},
+		{"solo square 2 and noise", func(a *APU) {
+			a.SetSolo(ChannelSquare2, true)
+			a.SetSolo(ChannelNoise, true)
+		}, true, true},
+		{"muted solo", func(a *APU) {
+			a.SetSolo(ChannelNoise, true)
+			a.SetMuted(ChannelNoise, true)
+		}, false, false},
+	}
+
+	for _, tt := range tests {
+		a := newTestMix()
+		tt.setup(a)
+		tickAPU(a, 17556)
+
+		// NR51 puts the noise on the left and square 2 on the right
+		left, right := peaks(a.DrainSamples())
+		if (left != 0) != tt.left || (right != 0) != tt.right {
+			t.Errorf("%s: want: left %t, right %t; got peaks %f, %f", tt.name, tt.left, tt.right, left, right)
+		}
+	}
+}
+
+func TestAPUStems(t *testing.T) {
+	a := newTestMix()
+	a.SetMuted(ChannelSquare2, true)
+	a.EnableStems(true)
+	tickAPU(a, 17556)
+
+	mix := a.DrainSamples()
+	stems := a.DrainStems()
+
+	want := []struct {
+		channel     Channel
+		left, right bool
+	}{
+		{ChannelSquare1, false, false},
+		{ChannelSquare2, false, true},
+		{ChannelWave, false, false},
+		{ChannelNoise, true, false},
+	}
+	for _, w := range want {
+		stem := stems[w.channel]
+		if len(stem) != len(mix) {
+			t.Fatalf("%s: want: %d samples; got %d", w.channel, len(mix), len(stem))
+		}
+		left, right := peaks(stem)
+		if (left != 0) != w.left || (right != 0) != w.right {
+			t.Errorf("%s: want: left %t, right %t; got peaks %f, %f", w.channel, w.left, w.right, left, right)
+		}
+	}
+
+	// Stems of channels playing alone add up to the mix
+	for i := range mix {
+		if mix[i] != stems[ChannelNoise][i] {
+			t.Fatalf("sample %d: want: mix equal to the noise stem; got %f and %f", i, mix[i], stems[ChannelNoise][i])
+		}
+	}
+}
+
+func TestParseChannel(t *testing.T) {
+	for c := ChannelSquare1; c <= ChannelNoise; c++ {
+		if got, err := ParseChannel(c.String()); err != nil || got != c {
+			t.Errorf("%s: want: %d; got %d, %v", c, c, got, err)
+		}
+	}
+	if _, err := ParseChannel("drums"); err == nil {
+		t.Errorf("want: error for unknown channels")
+	}
+}
@@ -0,0 +1,104 @@
+// Package bus routes memory accesses to the regions mapped on the 16-bit
+// address space
+package bus
+
+import "fmt"
+
+// Region is anything mapped on the bus that reacts to reads and writes.
+// Addresses are always absolute, not relative to the start of the region.
+type Region interface {
+	Read(addr uint16) uint8
+	Write(addr uint16, value uint8)
+}
+
+type Bus interface {
+	Region
+	Map(start, end uint16, region Region)
+}
+
+// MemoryBus routes each access to the region mapped at its address. Below
+// 0xFE00 regions are looked up per 256 bytes page, above it they are looked
+// up per address since OAM, I/O registers, HRAM and IE sit side by side.
+type MemoryBus struct {
+	pages [0xFE]Region
+	high  [0x200]Region
+}
+
+func NewMemoryBus() *MemoryBus {
+	bus := new(MemoryBus)
+
+	bus.Map(0x0000, 0xFFFF, OpenBus{})
+
+	return bus
+}
+
+// Map makes region handle every address from start to end included,
+// replacing whatever was mapped there before.
+func (b *MemoryBus) Map(start, end uint16, region Region) {
+	if start < 0xFE00 && (start&0xFF != 0 || (end < 0xFE00 && end&0xFF != 0xFF)) {
+		panic(fmt.Sprintf("bus: mapping %04X-%04X is not aligned on pages", start, end))
+	}
+
+	for addr := uint32(start); addr <= uint32(end); addr++ {
+		if addr >= 0xFE00 {
+			b.high[addr-0xFE00] = region
+		} else {
+			b.pages[addr>>8] = region
+		}
+	}
+}
+
+func (b *MemoryBus) region(addr uint16) Region {
+	if addr >= 0xFE00 {
+		return b.high[addr-0xFE00]
+	}
+	return b.pages[addr>>8]
+}
+
+func (b *MemoryBus) Read(addr uint16) uint8 {
+	return b.region(addr).Read(addr)
+}
+
+func (b *MemoryBus) Write(addr uint16, value uint8) {
+	b.region(addr).Write(addr, value)
+}
+
+// OpenBus is what answers where nothing is mapped
+type OpenBus struct{}
+
+func (OpenBus) Read(addr uint16) uint8         { return 0xFF }
+func (OpenBus) Write(addr uint16, value uint8) {}
+
+// Unusable is the 0xFEA0-0xFEFF area, which reads as 0 on DMG
+type Unusable struct{}
+
+func (Unusable) Read(addr uint16) uint8         { return 0x00 }
+func (Unusable) Write(addr uint16, value uint8) {}
+
+// RAM is plain read/write memory starting at Base
+type RAM struct {
+	Base uint16
+	Data []uint8
+}
+
+func NewRAM(base uint16, size int) *RAM {
+	ram := new(RAM)
+
+	ram.Base = base
+	ram.Data = make([]uint8, size)
+
+	return ram
+}
+
+func (r *RAM) Read(addr uint16) uint8         { return r.Data[addr-r.Base] }
+func (r *RAM) Write(addr uint16, value uint8) { r.Data[addr-r.Base] = value }
+
+// Mirror forwards accesses to another region, Offset bytes lower. It is used
+// for echo RAM at 0xE000-0xFDFF which mirrors WRAM at 0xC000-0xDDFF.
+type Mirror struct {
+	Region Region
+	Offset uint16
+}
+
+func (m Mirror) Read(addr uint16) uint8         { return m.Region.Read(addr - m.Offset) }
+func (m Mirror) Write(addr uint16, value uint8) { m.Region.Write(addr-m.Offset, value) }
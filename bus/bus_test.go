@@ -0,0 +1,54 @@
+package bus
+
+import "testing"
+
+type writeLog struct {
+	writes []uint16
+}
+
+func (w *writeLog) Read(addr uint16) uint8         { return uint8(addr) }
+func (w *writeLog) Write(addr uint16, value uint8) { w.writes = append(w.writes, addr) }
+
+func TestBusOpen(t *testing.T) {
+	bus := NewMemoryBus()
+
+	bus.Write(0x1234, 0x00)
+
+	if bus.Read(0x1234) != 0xFF {
+		t.Errorf("want: 0xFF; got %x", bus.Read(0x1234))
+	}
+}
+
+func TestBusMap(t *testing.T) {
+	bus := NewMemoryBus()
+	log := new(writeLog)
+
+	bus.Map(0x4000, 0x7FFF, log)
+	bus.Map(0xFF05, 0xFF05, log)
+
+	if bus.Read(0x4012) != 0x12 {
+		t.Errorf("want: 0x12; got %x", bus.Read(0x4012))
+	}
+	if bus.Read(0x3FFF) != 0xFF || bus.Read(0x8000) != 0xFF {
+		t.Errorf("want: unmapped around region; got %x %x", bus.Read(0x3FFF), bus.Read(0x8000))
+	}
+	if bus.Read(0xFF04) != 0xFF || bus.Read(0xFF05) != 0x05 || bus.Read(0xFF06) != 0xFF {
+		t.Errorf("want: only 0xFF05 mapped; got %x %x %x", bus.Read(0xFF04), bus.Read(0xFF05), bus.Read(0xFF06))
+	}
+
+	bus.Write(0x7FFF, 0x00)
+	bus.Write(0xFF05, 0x00)
+	if len(log.writes) != 2 || log.writes[0] != 0x7FFF || log.writes[1] != 0xFF05 {
+		t.Errorf("want: writes to 7fff ff05; got %x", log.writes)
+	}
+}
+
+func TestBusUnalignedMap(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("want: panic on unaligned mapping")
+		}
+	}()
+
+	NewMemoryBus().Map(0x4010, 0x7FFF, new(writeLog))
+}
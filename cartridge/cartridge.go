@@ -0,0 +1,450 @@
+// Package cartridge loads ROM images and emulates the memory bank
+// controllers, real time clock and battery backed RAM of cartridges
+package cartridge
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/hmatheisen/gameboy/bus"
+)
+
+var (
+	ErrTruncated      = errors.New("cartridge: image truncated")
+	ErrInconsistent   = errors.New("cartridge: inconsistent header")
+	ErrHeaderChecksum = errors.New("cartridge: header checksum mismatch")
+	ErrGlobalChecksum = errors.New("cartridge: global checksum mismatch")
+	ErrUnsupported    = errors.New("cartridge: unsupported cartridge type")
+)
+
+// Controller identifies the memory bank controller family of a cartridge
+type Controller int
+
+const (
+	ControllerNone Controller = iota
+	ControllerMBC1
+	ControllerMBC2
+	ControllerMMM01
+	ControllerMBC3
+	ControllerMBC5
+	ControllerMBC6
+	ControllerMBC7
+	ControllerCamera
+	ControllerTAMA5
+	ControllerHuC3
+	ControllerHuC1
+)
+
+type Type uint8
+
+type cartridgeTypeInfo struct {
+	name       string
+	controller Controller
+	ram        bool
+	battery    bool
+	timer      bool
+	rumble     bool
+}
+
+var cartridgeTypes = map[Type]cartridgeTypeInfo{
+	0x00: {"ROM ONLY", ControllerNone, false, false, false, false},
+	0x01: {"MBC1", ControllerMBC1, false, false, false, false},
+	0x02: {"MBC1+RAM", ControllerMBC1, true, false, false, false},
+	0x03: {"MBC1+RAM+BATTERY", ControllerMBC1, true, true, false, false},
+	0x05: {"MBC2", ControllerMBC2, false, false, false, false},
+	0x06: {"MBC2+BATTERY", ControllerMBC2, false, true, false, false},
+	0x08: {"ROM+RAM", ControllerNone, true, false, false, false},
+	0x09: {"ROM+RAM+BATTERY", ControllerNone, true, true, false, false},
+	0x0B: {"MMM01", ControllerMMM01, false, false, false, false},
+	0x0C: {"MMM01+RAM", ControllerMMM01, true, false, false, false},
+	0x0D: {"MMM01+RAM+BATTERY", ControllerMMM01, true, true, false, false},
+	0x0F: {"MBC3+TIMER+BATTERY", ControllerMBC3, false, true, true, false},
+	0x10: {"MBC3+TIMER+RAM+BATTERY", ControllerMBC3, true, true, true, false},
+	0x11: {"MBC3", ControllerMBC3, false, false, false, false},
+	0x12: {"MBC3+RAM", ControllerMBC3, true, false, false, false},
+	0x13: {"MBC3+RAM+BATTERY", ControllerMBC3, true, true, false, false},
+	0x19: {"MBC5", ControllerMBC5, false, false, false, false},
+	0x1A: {"MBC5+RAM", ControllerMBC5, true, false, false, false},
+	0x1B: {"MBC5+RAM+BATTERY", ControllerMBC5, true, true, false, false},
+	0x1C: {"MBC5+RUMBLE", ControllerMBC5, false, false, false, true},
+	0x1D: {"MBC5+RUMBLE+RAM", ControllerMBC5, true, false, false, true},
+	0x1E: {"MBC5+RUMBLE+RAM+BATTERY", ControllerMBC5, true, true, false, true},
+	0x20: {"MBC6", ControllerMBC6, false, false, false, false},
+	0x22: {"MBC7+SENSOR+RUMBLE+RAM+BATTERY", ControllerMBC7, true, true, false, true},
+	0xFC: {"POCKET CAMERA", ControllerCamera, true, false, false, false},
+	0xFD: {"BANDAI TAMA5", ControllerTAMA5, false, false, false, false},
+	0xFE: {"HuC3", ControllerHuC3, false, false, false, false},
+	0xFF: {"HuC1+RAM+BATTERY", ControllerHuC1, true, true, false, false},
+}
+
+func (t Type) String() string {
+	if info, ok := cartridgeTypes[t]; ok {
+		return info.name
+	}
+	return fmt.Sprintf("UNKNOWN (%02X)", uint8(t))
+}
+
+func (t Type) Controller() Controller { return cartridgeTypes[t].controller }
+func (t Type) HasRAM() bool           { return cartridgeTypes[t].ram }
+func (t Type) HasBattery() bool       { return cartridgeTypes[t].battery }
+func (t Type) HasTimer() bool         { return cartridgeTypes[t].timer }
+func (t Type) HasRumble() bool        { return cartridgeTypes[t].rumble }
+
+// ROM sizes by header code, codes 0x52-0x54 only appear in a few unofficial
+// documents but are accepted anyway
+var romSizes = map[uint8]int{
+	0x00: 32 << 10,
+	0x01: 64 << 10,
+	0x02: 128 << 10,
+	0x03: 256 << 10,
+	0x04: 512 << 10,
+	0x05: 1 << 20,
+	0x06: 2 << 20,
+	0x07: 4 << 20,
+	0x08: 8 << 20,
+	0x52: 72 * 16 << 10,
+	0x53: 80 * 16 << 10,
+	0x54: 96 * 16 << 10,
+}
+
+var ramSizes = map[uint8]int{
+	0x00: 0,
+	0x01: 2 << 10,
+	0x02: 8 << 10,
+	0x03: 32 << 10,
+	0x04: 128 << 10,
+	0x05: 64 << 10,
+}
+
+// Header is the cartridge header found at 0x0100-0x014F
+type Header struct {
+	Title            string
+	ManufacturerCode string
+	CGBFlag          uint8
+	NewLicenseeCode  string
+	SGBFlag          uint8
+	Type             Type
+	ROMSize          int // in bytes
+	RAMSize          int // in bytes
+	Destination      uint8
+	OldLicenseeCode  uint8
+	Version          uint8
+	HeaderChecksum   uint8
+	GlobalChecksum   uint16
+}
+
+// ParseHeader decodes the header of a ROM image without checking it against
+// the rest of the image
+func ParseHeader(rom []uint8) (Header, error) {
+	var h Header
+
+	if len(rom) < 0x150 {
+		return h, fmt.Errorf("%w: %d bytes is too short to hold a header", ErrTruncated, len(rom))
+	}
+
+	h.CGBFlag = rom[0x143]
+
+	// On CGB cartridges the end of the title area may hold a 4 characters
+	// manufacturer code and the last byte is the CGB flag
+	title := rom[0x134:0x144]
+	if h.CGBFlag&0x80 != 0 {
+		title = rom[0x134:0x143]
+		if isManufacturerCode(rom[0x13F:0x143]) {
+			h.ManufacturerCode = string(rom[0x13F:0x143])
+			title = rom[0x134:0x13F]
+		}
+	}
+	h.Title = strings.TrimRight(string(title[:indexNUL(title)]), " ")
+
+	h.NewLicenseeCode = string(rom[0x144:0x146])
+	h.SGBFlag = rom[0x146]
+	h.Type = Type(rom[0x147])
+	h.Destination = rom[0x14A]
+	h.OldLicenseeCode = rom[0x14B]
+	h.Version = rom[0x14C]
+	h.HeaderChecksum = rom[0x14D]
+	h.GlobalChecksum = uint16(rom[0x14E])<<8 | uint16(rom[0x14F])
+
+	romSize, ok := romSizes[rom[0x148]]
+	if !ok {
+		return h, fmt.Errorf("%w: unknown ROM size code %02X", ErrInconsistent, rom[0x148])
+	}
+	h.ROMSize = romSize
+
+	ramSize, ok := ramSizes[rom[0x149]]
+	if !ok {
+		return h, fmt.Errorf("%w: unknown RAM size code %02X", ErrInconsistent, rom[0x149])
+	}
+	h.RAMSize = ramSize
+
+	return h, nil
+}
+
+func isManufacturerCode(code []uint8) bool {
+	for _, c := range code {
+		if (c < 'A' || c > 'Z') && (c < '0' || c > '9') {
+			return false
+		}
+	}
+	return true
+}
+
+func indexNUL(s []uint8) int {
+	for i, c := range s {
+		if c == 0 {
+			return i
+		}
+	}
+	return len(s)
+}
+
+// Licensee returns the publisher code, which moved to the new licensee field
+// when the old one is 0x33
+func (h Header) Licensee() string {
+	if h.OldLicenseeCode == 0x33 {
+		return h.NewLicenseeCode
+	}
+	return fmt.Sprintf("%02X", h.OldLicenseeCode)
+}
+
+func headerChecksum(rom []uint8) uint8 {
+	var sum uint8
+	for _, b := range rom[0x134:0x14D] {
+		sum = sum - b - 1
+	}
+	return sum
+}
+
+func globalChecksum(rom []uint8) uint16 {
+	var sum uint16
+	for i, b := range rom {
+		if i != 0x14E && i != 0x14F {
+			sum += uint16(b)
+		}
+	}
+	return sum
+}
+
+// Verify checks the header against the whole ROM image. Bytes past the size
+// the header declares, as found in overdumps, are ignored
+func (h Header) Verify(rom []uint8) error {
+	if err := h.verifyHeader(rom); err != nil {
+		return err
+	}
+
+	if sum := globalChecksum(rom[:h.ROMSize]); sum != h.GlobalChecksum {
+		return fmt.Errorf("%w: computed %04X, header says %04X", ErrGlobalChecksum, sum, h.GlobalChecksum)
+	}
+
+	return nil
+}
+
+// The checks of Verify but the global checksum, which the hardware never
+// looks at and many homebrew and patched ROMs get wrong
+func (h Header) verifyHeader(rom []uint8) error {
+	if len(rom) < h.ROMSize {
+		return fmt.Errorf("%w: image is %d bytes, header declares %d", ErrTruncated, len(rom), h.ROMSize)
+	}
+
+	if _, ok := cartridgeTypes[h.Type]; !ok {
+		return fmt.Errorf("%w: unknown cartridge type %02X", ErrInconsistent, uint8(h.Type))
+	}
+	if h.Type.Controller() == ControllerNone && h.ROMSize > 32<<10 {
+		return fmt.Errorf("%w: %s cartridge with %d KiB of ROM", ErrInconsistent, h.Type, h.ROMSize>>10)
+	}
+	if h.Type.Controller() == ControllerMBC2 && h.RAMSize != 0 {
+		return fmt.Errorf("%w: MBC2 cartridge declares %d KiB of external RAM", ErrInconsistent, h.RAMSize>>10)
+	}
+
+	if sum := headerChecksum(rom); sum != h.HeaderChecksum {
+		return fmt.Errorf("%w: computed %02X, header says %02X", ErrHeaderChecksum, sum, h.HeaderChecksum)
+	}
+
+	return nil
+}
+
+type Cartridge struct {
+	Header Header
+	ROM    []uint8
+	RAM    []uint8
+
+	// RTC is only present on MBC3 cartridges with a timer
+	RTC *RTC
+
+	// OnRumble is called when a rumble cartridge turns its motor on or off
+	OnRumble func(on bool)
+
+	// SavePath is where battery backed RAM is persisted
+	SavePath string
+
+	// Warnings are problems with the image the cartridge runs anyway, such as
+	// a wrong global checksum
+	Warnings []error
+
+	// Set when RAM changed since the last save, flushPending asks for a save
+	// at the next opportunity and sinceSave counts cycles for autosaves
+	dirty        bool
+	flushPending bool
+	sinceSave    int
+
+	// mbc sits between the bus and the ROM and RAM chips
+	mbc bus.Region
+}
+
+// LoadOption changes where Load looks for the save of a cartridge
+type LoadOption func(*loadConfig)
+
+type loadConfig struct {
+	saveDir string
+	noSave  bool
+}
+
+// WithSaveDir keeps the save in dir instead of next to the ROM
+func WithSaveDir(dir string) LoadOption {
+	return func(c *loadConfig) {
+		c.saveDir = dir
+	}
+}
+
+// WithoutSave reads only the ROM, battery backed RAM starts blank and is never
+// written back
+func WithoutSave() LoadOption {
+	return func(c *loadConfig) {
+		c.noSave = true
+	}
+}
+
+// Load reads the ROM at path and, for cartridges with a battery, the .sav
+// file named after it
+func Load(path string, options ...LoadOption) (*Cartridge, error) {
+	config := new(loadConfig)
+	for _, option := range options {
+		option(config)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	cart, err := New(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	if cart.Header.Type.HasBattery() && !config.noSave {
+		cart.SavePath = strings.TrimSuffix(path, filepath.Ext(path)) + ".sav"
+		if config.saveDir != "" {
+			cart.SavePath = filepath.Join(config.saveDir, filepath.Base(cart.SavePath))
+		}
+
+		err := cart.LoadSaveFile(cart.SavePath)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+	}
+
+	return cart, nil
+}
+
+func New(rom []uint8) (*Cartridge, error) {
+	header, err := ParseHeader(rom)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := header.verifyHeader(rom); err != nil {
+		return nil, err
+	}
+
+	cart := new(Cartridge)
+
+	cart.Header = header
+	cart.ROM = rom[:header.ROMSize]
+	cart.RAM = make([]uint8, header.RAMSize)
+
+	if sum := globalChecksum(cart.ROM); sum != header.GlobalChecksum {
+		cart.Warnings = append(cart.Warnings, fmt.Errorf("%w: computed %04X, header says %04X", ErrGlobalChecksum, sum, header.GlobalChecksum))
+	}
+
+	if header.Type.HasTimer() {
+		cart.RTC = NewRTC()
+	}
+
+	switch header.Type.Controller() {
+	case ControllerNone:
+		cart.mbc = &noMBC{cart: cart}
+	case ControllerMBC1:
+		cart.mbc = NewMBC1(cart)
+	case ControllerMBC3:
+		cart.mbc = NewMBC3(cart)
+	case ControllerMBC5:
+		cart.mbc = NewMBC5(cart)
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupported, header.Type)
+	}
+
+	return cart, nil
+}
+
+// Tick lets cartridge hardware follow emulated time
+func (c *Cartridge) Tick(cycles int) {
+	if c.RTC != nil {
+		c.RTC.Tick(cycles)
+	}
+
+	c.sinceSave += cycles
+	if c.sinceSave >= autosaveCycles {
+		c.sinceSave = 0
+		c.flushPending = c.dirty
+	}
+}
+
+func (c *Cartridge) writeRAM(offset int, value uint8) {
+	c.RAM[offset] = value
+	c.dirty = true
+}
+
+// Games disable RAM once they are done writing to it, which makes a good
+// time to save
+func (c *Cartridge) setRAMEnabled(was, enabled bool) bool {
+	if was && !enabled && c.dirty {
+		c.flushPending = true
+	}
+	return enabled
+}
+
+func (c *Cartridge) Read(addr uint16) uint8         { return c.mbc.Read(addr) }
+func (c *Cartridge) Write(addr uint16, value uint8) { c.mbc.Write(addr, value) }
+
+// noMBC wires up to 32 KiB of ROM and 8 KiB of RAM directly on the bus
+type noMBC struct {
+	cart *Cartridge
+}
+
+func (m *noMBC) Read(addr uint16) uint8 {
+	if addr < 0x8000 {
+		return m.cart.ROM[addr]
+	}
+
+	offset := int(addr - 0xA000)
+	if offset < len(m.cart.RAM) {
+		return m.cart.RAM[offset]
+	}
+	return 0xFF
+}
+
+func (m *noMBC) Write(addr uint16, value uint8) {
+	if addr < 0x8000 {
+		return
+	}
+
+	offset := int(addr - 0xA000)
+	if offset < len(m.cart.RAM) {
+		m.cart.writeRAM(offset, value)
+	}
+}
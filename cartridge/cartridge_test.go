@@ -0,0 +1,198 @@
+package cartridge
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Builds a valid image where the first two bytes of every 16 KiB bank hold
+// the bank number and a made up logo fills 0x104-0x133
+func makeROM(typ Type, romCode, ramCode uint8) []uint8 {
+	rom := make([]uint8, romSizes[romCode])
+	for bank := 0; bank < len(rom)/0x4000; bank++ {
+		rom[bank*0x4000] = uint8(bank)
+		rom[bank*0x4000+1] = uint8(bank >> 8)
+	}
+
+	for i := 0x104; i < 0x134; i++ {
+		rom[i] = uint8(i)
+	}
+	copy(rom[0x134:], "TEST")
+	rom[0x147] = uint8(typ)
+	rom[0x148] = romCode
+	rom[0x149] = ramCode
+	rom[0x14B] = 0x01
+	fixChecksums(rom)
+
+	return rom
+}
+
+func fixChecksums(rom []uint8) {
+	rom[0x14D] = headerChecksum(rom)
+	sum := globalChecksum(rom)
+	rom[0x14E] = uint8(sum >> 8)
+	rom[0x14F] = uint8(sum)
+}
+
+func TestParseHeader(t *testing.T) {
+	rom := makeROM(0x03, 0x02, 0x03)
+	copy(rom[0x134:0x144], "POKEMON RED\x00\x00\x00\x00\x00")
+	rom[0x146] = 0x03
+	rom[0x14A] = 0x01
+	rom[0x14B] = 0x33
+	copy(rom[0x144:], "01")
+	rom[0x14C] = 0x02
+	fixChecksums(rom)
+
+	h, err := ParseHeader(rom)
+	if err != nil {
+		t.Fatalf("want: no error; got %v", err)
+	}
+	if err := h.Verify(rom); err != nil {
+		t.Fatalf("want: no error; got %v", err)
+	}
+
+	if h.Title != "POKEMON RED" {
+		t.Errorf("want: title = POKEMON RED; got title = %q", h.Title)
+	}
+	if h.ManufacturerCode != "" {
+		t.Errorf("want: no manufacturer code; got %q", h.ManufacturerCode)
+	}
+	if h.SGBFlag != 0x03 || h.Destination != 0x01 || h.Version != 0x02 {
+		t.Errorf("want: SGB = 03, destination = 01, version = 02; got %02x %02x %02x", h.SGBFlag, h.Destination, h.Version)
+	}
+	if h.Type.String() != "MBC1+RAM+BATTERY" || !h.Type.HasBattery() || !h.Type.HasRAM() {
+		t.Errorf("want: MBC1+RAM+BATTERY; got %s", h.Type)
+	}
+	if h.ROMSize != 128<<10 || h.RAMSize != 32<<10 {
+		t.Errorf("want: 128 KiB ROM, 32 KiB RAM; got %d, %d", h.ROMSize, h.RAMSize)
+	}
+	if h.Licensee() != "01" {
+		t.Errorf("want: licensee = 01; got %s", h.Licensee())
+	}
+}
+
+func TestParseHeaderCGB(t *testing.T) {
+	rom := makeROM(0x00, 0x00, 0x00)
+	copy(rom[0x134:0x144], "ZELDA\x00\x00\x00\x00\x00\x00AZ7E\x80")
+
+	h, err := ParseHeader(rom)
+	if err != nil {
+		t.Fatalf("want: no error; got %v", err)
+	}
+
+	if h.Title != "ZELDA" || h.ManufacturerCode != "AZ7E" || h.CGBFlag != 0x80 {
+		t.Errorf("want: ZELDA AZ7E 80; got %q %q %02x", h.Title, h.ManufacturerCode, h.CGBFlag)
+	}
+	if h.Licensee() != "01" {
+		t.Errorf("want: licensee = 01; got %s", h.Licensee())
+	}
+}
+
+func TestCartridgeErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		rom    func() []uint8
+		target error
+	}{
+		{"short header", func() []uint8 { return make([]uint8, 0x100) }, ErrTruncated},
+		{"truncated image", func() []uint8 { return makeROM(0x01, 0x02, 0x00)[:0x8000] }, ErrTruncated},
+		{"unknown ROM size", func() []uint8 {
+			rom := makeROM(0x00, 0x00, 0x00)
+			rom[0x148] = 0x42
+			return rom
+		}, ErrInconsistent},
+		{"unknown RAM size", func() []uint8 {
+			rom := makeROM(0x00, 0x00, 0x00)
+			rom[0x149] = 0x42
+			return rom
+		}, ErrInconsistent},
+		{"unknown type", func() []uint8 { return makeROM(0x42, 0x00, 0x00) }, ErrInconsistent},
+		{"ROM only with banks", func() []uint8 { return makeROM(0x00, 0x01, 0x00) }, ErrInconsistent},
+		{"MBC2 with RAM", func() []uint8 { return makeROM(0x05, 0x01, 0x02) }, ErrInconsistent},
+		{"header checksum", func() []uint8 {
+			rom := makeROM(0x00, 0x00, 0x00)
+			rom[0x14D]++
+			return rom
+		}, ErrHeaderChecksum},
+		{"unsupported", func() []uint8 { return makeROM(0xFE, 0x00, 0x00) }, ErrUnsupported},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New(tt.rom())
+			if !errors.Is(err, tt.target) {
+				t.Errorf("want: %v; got %v", tt.target, err)
+			}
+		})
+	}
+}
+
+func TestCartridgeLenient(t *testing.T) {
+	// Overdumped, with a global checksum left stale by a patch
+	rom := append(makeROM(0x00, 0x00, 0x00), make([]uint8, 0x8000)...)
+	rom[0x4000]++
+
+	cart, err := New(rom)
+	if err != nil {
+		t.Fatalf("want: no error; got %v", err)
+	}
+	if len(cart.ROM) != 32<<10 {
+		t.Errorf("want: %d bytes of ROM; got %d", 32<<10, len(cart.ROM))
+	}
+	if len(cart.Warnings) != 1 || !errors.Is(cart.Warnings[0], ErrGlobalChecksum) {
+		t.Errorf("want: %v warning; got %v", ErrGlobalChecksum, cart.Warnings)
+	}
+
+	if err := cart.Header.Verify(rom); !errors.Is(err, ErrGlobalChecksum) {
+		t.Errorf("want: %v; got %v", ErrGlobalChecksum, err)
+	}
+}
+
+func TestLoadCartridge(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.gb")
+	if err := os.WriteFile(path, makeROM(0x00, 0x00, 0x00), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cart, err := Load(path)
+	if err != nil {
+		t.Fatalf("want: no error; got %v", err)
+	}
+	if cart.Header.Title != "TEST" {
+		t.Errorf("want: title = TEST; got title = %q", cart.Header.Title)
+	}
+	if len(cart.Warnings) != 0 {
+		t.Errorf("want: no warnings; got %v", cart.Warnings)
+	}
+
+	if _, err := Load(path + ".missing"); err == nil {
+		t.Errorf("want: error for missing file")
+	}
+}
+
+func TestROMOnly(t *testing.T) {
+	rom := makeROM(0x08, 0x00, 0x02)
+	rom[0x1234] = 0x56
+	fixChecksums(rom)
+
+	cart, err := New(rom)
+	if err != nil {
+		t.Fatalf("want: no error; got %v", err)
+	}
+
+	cart.Write(0x1234, 0x00)
+	if cart.Read(0x1234) != 0x56 {
+		t.Errorf("want: ROM = 0x56; got %x", cart.Read(0x1234))
+	}
+	if cart.Read(0x4000) != 0x01 {
+		t.Errorf("want: bank 1 at 0x4000; got %x", cart.Read(0x4000))
+	}
+
+	cart.Write(0xBFFF, 0x78)
+	if cart.Read(0xBFFF) != 0x78 {
+		t.Errorf("want: RAM = 0x78; got %x", cart.Read(0xBFFF))
+	}
+}
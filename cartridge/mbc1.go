@@ -0,0 +1,102 @@
+package cartridge
+
+import "bytes"
+
+// MBC1 maps up to 2 MiB of ROM and 32 KiB of RAM. BANK1 holds the lower 5
+// bits of the ROM bank, BANK2 holds 2 more bits used either for the upper ROM
+// bank bits or the RAM bank depending on the banking mode.
+type MBC1 struct {
+	cart *Cartridge
+
+	ramEnabled bool
+	bank1      uint8
+	bank2      uint8
+	mode       uint8
+
+	// MBC1M multicarts wire BANK2 one bit lower, so it selects one of four
+	// 256 KiB games and only 4 bits of BANK1 are used
+	multicart bool
+}
+
+func NewMBC1(cart *Cartridge) *MBC1 {
+	mbc := new(MBC1)
+
+	mbc.cart = cart
+	mbc.bank1 = 1
+	mbc.multicart = isMBC1Multicart(cart.ROM)
+
+	return mbc
+}
+
+// Multicarts are 1 MiB images where each game has its own header, which is
+// spotted by finding the boot logo again at the start of bank 0x10
+func isMBC1Multicart(rom []uint8) bool {
+	if len(rom) != 1<<20 {
+		return false
+	}
+
+	logo := rom[0x104:0x134]
+	return bytes.Equal(rom[0x10*0x4000+0x104:0x10*0x4000+0x134], logo)
+}
+
+func (m *MBC1) romBank(addr uint16) int {
+	shift, bank1 := 5, m.bank1
+	if m.multicart {
+		shift, bank1 = 4, m.bank1&0x0F
+	}
+
+	upper := 0
+	if addr >= 0x4000 || m.mode == 1 {
+		upper = int(m.bank2) << shift
+	}
+
+	if addr < 0x4000 {
+		return upper
+	}
+	return upper | int(bank1)
+}
+
+func (m *MBC1) ramOffset(addr uint16) int {
+	bank := 0
+	if m.mode == 1 {
+		bank = int(m.bank2)
+	}
+
+	return (bank*0x2000 + int(addr-0xA000)) % len(m.cart.RAM)
+}
+
+func (m *MBC1) Read(addr uint16) uint8 {
+	if addr < 0x8000 {
+		banks := len(m.cart.ROM) / 0x4000
+		bank := m.romBank(addr) % banks
+
+		return m.cart.ROM[bank*0x4000+int(addr&0x3FFF)]
+	}
+
+	if !m.ramEnabled || len(m.cart.RAM) == 0 {
+		return 0xFF
+	}
+	return m.cart.RAM[m.ramOffset(addr)]
+}
+
+func (m *MBC1) Write(addr uint16, value uint8) {
+	switch {
+	case addr < 0x2000:
+		m.ramEnabled = m.cart.setRAMEnabled(m.ramEnabled, value&0x0F == 0x0A)
+	case addr < 0x4000:
+		// Bank 0 cannot be selected in the switchable area, writing 0 maps
+		// bank 1 instead, which is checked on all 5 bits
+		m.bank1 = value & 0x1F
+		if m.bank1 == 0 {
+			m.bank1 = 1
+		}
+	case addr < 0x6000:
+		m.bank2 = value & 0x03
+	case addr < 0x8000:
+		m.mode = value & 0x01
+	default:
+		if m.ramEnabled && len(m.cart.RAM) > 0 {
+			m.cart.writeRAM(m.ramOffset(addr), value)
+		}
+	}
+}
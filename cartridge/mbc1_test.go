@@ -0,0 +1,199 @@
+package cartridge
+
+import "testing"
+
+func newTestCartridge(t *testing.T, rom []uint8) *Cartridge {
+	t.Helper()
+
+	cart, err := New(rom)
+	if err != nil {
+		t.Fatalf("want: no error; got %v", err)
+	}
+
+	return cart
+}
+
+// One test per MBC1 register, then RAM sizes, small ROMs and multicarts
+func TestMBC1BitsBank1(t *testing.T) {
+	cart := newTestCartridge(t, makeROM(0x01, 0x06, 0x00))
+
+	tests := []struct {
+		value uint8
+		bank  uint8
+	}{
+		{0x00, 0x01},
+		{0x01, 0x01},
+		{0x02, 0x02},
+		{0x1F, 0x1F},
+		{0x20, 0x01},
+		{0x21, 0x01},
+		{0xE5, 0x05},
+	}
+
+	for _, tt := range tests {
+		cart.Write(0x2000, tt.value)
+		if got := cart.Read(0x4000); got != tt.bank {
+			t.Errorf("BANK1 = %02x: want: bank %02x; got bank %02x", tt.value, tt.bank, got)
+		}
+	}
+}
+
+func TestMBC1BitsBank2(t *testing.T) {
+	cart := newTestCartridge(t, makeROM(0x01, 0x06, 0x00))
+
+	cart.Write(0x2000, 0x04)
+	for bank2 := uint8(0); bank2 < 8; bank2++ {
+		cart.Write(0x4000, bank2)
+		want := (bank2&0x03)<<5 | 0x04
+		if got := cart.Read(0x4000); got != want {
+			t.Errorf("BANK2 = %d: want: bank %02x; got bank %02x", bank2, want, got)
+		}
+	}
+}
+
+func TestMBC1BitsMode(t *testing.T) {
+	cart := newTestCartridge(t, makeROM(0x01, 0x06, 0x00))
+	cart.Write(0x4000, 0x02)
+
+	if got := cart.Read(0x0000); got != 0x00 {
+		t.Errorf("mode 0: want: bank 00 at 0x0000; got bank %02x", got)
+	}
+
+	cart.Write(0x6000, 0x01)
+	if got := cart.Read(0x0000); got != 0x40 {
+		t.Errorf("mode 1: want: bank 40 at 0x0000; got bank %02x", got)
+	}
+	if got := cart.Read(0x4000); got != 0x41 {
+		t.Errorf("mode 1: want: bank 41 at 0x4000; got bank %02x", got)
+	}
+
+	cart.Write(0x6000, 0xFE)
+	if got := cart.Read(0x0000); got != 0x00 {
+		t.Errorf("mode 0: want: bank 00 at 0x0000; got bank %02x", got)
+	}
+}
+
+func TestMBC1BitsRAMG(t *testing.T) {
+	cart := newTestCartridge(t, makeROM(0x02, 0x01, 0x02))
+
+	tests := []struct {
+		value   uint8
+		enabled bool
+	}{
+		{0x0A, true},
+		{0x00, false},
+		{0x1A, true},
+		{0x0B, false},
+		{0xFA, true},
+		{0xA0, false},
+	}
+
+	cart.Write(0x0000, 0x0A)
+	cart.Write(0xA000, 0x42)
+
+	for _, tt := range tests {
+		cart.Write(0x0000, tt.value)
+		got := cart.Read(0xA000) == 0x42
+		if got != tt.enabled {
+			t.Errorf("RAMG = %02x: want: enabled = %t; got enabled = %t", tt.value, tt.enabled, got)
+		}
+	}
+
+	cart.Write(0x0000, 0x00)
+	cart.Write(0xA000, 0x24)
+	cart.Write(0x0000, 0x0A)
+	if cart.Read(0xA000) != 0x42 {
+		t.Errorf("want: write ignored while disabled; got %02x", cart.Read(0xA000))
+	}
+}
+
+func TestMBC1RAM32KiB(t *testing.T) {
+	cart := newTestCartridge(t, makeROM(0x03, 0x01, 0x03))
+	cart.Write(0x0000, 0x0A)
+
+	cart.Write(0x6000, 0x01)
+	for bank := uint8(0); bank < 4; bank++ {
+		cart.Write(0x4000, bank)
+		cart.Write(0xA000, 0x10+bank)
+	}
+
+	for bank := uint8(0); bank < 4; bank++ {
+		cart.Write(0x4000, bank)
+		if got := cart.Read(0xA000); got != 0x10+bank {
+			t.Errorf("RAM bank %d: want: %02x; got %02x", bank, 0x10+bank, got)
+		}
+	}
+
+	// Mode 0 always maps RAM bank 0
+	cart.Write(0x6000, 0x00)
+	if got := cart.Read(0xA000); got != 0x10 {
+		t.Errorf("mode 0: want: %02x; got %02x", 0x10, got)
+	}
+}
+
+func TestMBC1RAM8KiBIgnoresBank2(t *testing.T) {
+	cart := newTestCartridge(t, makeROM(0x03, 0x01, 0x02))
+	cart.Write(0x0000, 0x0A)
+	cart.Write(0x6000, 0x01)
+
+	cart.Write(0xA123, 0x42)
+	cart.Write(0x4000, 0x03)
+
+	if got := cart.Read(0xA123); got != 0x42 {
+		t.Errorf("want: 42; got %02x", got)
+	}
+}
+
+func TestMBC1SmallROMWrapsBanks(t *testing.T) {
+	cart := newTestCartridge(t, makeROM(0x01, 0x02, 0x00))
+
+	cart.Write(0x2000, 0x09)
+	if got := cart.Read(0x4000); got != 0x01 {
+		t.Errorf("128 KiB ROM, bank 9: want: bank 01; got bank %02x", got)
+	}
+
+	// BANK2 is ignored when the ROM is smaller than 1 MiB
+	cart.Write(0x4000, 0x01)
+	cart.Write(0x2000, 0x03)
+	if got := cart.Read(0x4000); got != 0x03 {
+		t.Errorf("128 KiB ROM, BANK2 set: want: bank 03; got bank %02x", got)
+	}
+}
+
+func TestMBC1Multicart(t *testing.T) {
+	rom := makeROM(0x01, 0x05, 0x00)
+	for game := 1; game < 4; game++ {
+		copy(rom[game*0x40000+0x104:], rom[0x104:0x150])
+	}
+	fixChecksums(rom)
+
+	cart := newTestCartridge(t, rom)
+
+	cart.Write(0x4000, 0x01)
+	cart.Write(0x2000, 0x12)
+	if got := cart.Read(0x4000); got != 0x12 {
+		t.Errorf("want: bank 12; got bank %02x", got)
+	}
+
+	// Bit 4 of BANK1 is not wired but still counts for the zero check
+	cart.Write(0x2000, 0x10)
+	if got := cart.Read(0x4000); got != 0x10 {
+		t.Errorf("want: bank 10; got bank %02x", got)
+	}
+
+	cart.Write(0x4000, 0x03)
+	cart.Write(0x6000, 0x01)
+	if got := cart.Read(0x0000); got != 0x30 {
+		t.Errorf("want: bank 30 at 0x0000; got bank %02x", got)
+	}
+}
+
+func TestMBC1NotMulticart(t *testing.T) {
+	cart := newTestCartridge(t, makeROM(0x01, 0x05, 0x00))
+
+	cart.Write(0x4000, 0x01)
+	cart.Write(0x2000, 0x12)
+	if got := cart.Read(0x4000); got != 0x32 {
+		t.Errorf("want: bank 32; got bank %02x", got)
+	}
+}
@@ -0,0 +1,85 @@
+package cartridge
+
+// MBC3 maps up to 2 MiB of ROM and 32 KiB of RAM, and optionally a real time
+// clock whose registers share the RAM area.
+type MBC3 struct {
+	cart *Cartridge
+
+	ramEnabled bool
+	romBank    uint8
+	// 0x00-0x07 selects a RAM bank, 0x08-0x0C a clock register
+	ramBank uint8
+}
+
+func NewMBC3(cart *Cartridge) *MBC3 {
+	mbc := new(MBC3)
+
+	mbc.cart = cart
+	mbc.romBank = 1
+
+	return mbc
+}
+
+func (m *MBC3) Read(addr uint16) uint8 {
+	switch {
+	case addr < 0x4000:
+		return m.cart.ROM[addr]
+	case addr < 0x8000:
+		bank := int(m.romBank) % (len(m.cart.ROM) / 0x4000)
+		return m.cart.ROM[bank*0x4000+int(addr-0x4000)]
+	}
+
+	if !m.ramEnabled {
+		return 0xFF
+	}
+
+	if m.ramBank >= 0x08 {
+		if m.cart.RTC == nil || m.ramBank > 0x0C {
+			return 0xFF
+		}
+		return m.cart.RTC.Read(m.ramBank)
+	}
+
+	if len(m.cart.RAM) == 0 {
+		return 0xFF
+	}
+	return m.cart.RAM[m.ramOffset(addr)]
+}
+
+func (m *MBC3) Write(addr uint16, value uint8) {
+	switch {
+	case addr < 0x2000:
+		m.ramEnabled = m.cart.setRAMEnabled(m.ramEnabled, value&0x0F == 0x0A)
+	case addr < 0x4000:
+		m.romBank = value & 0x7F
+		if m.romBank == 0 {
+			m.romBank = 1
+		}
+	case addr < 0x6000:
+		m.ramBank = value & 0x0F
+	case addr < 0x8000:
+		if m.cart.RTC != nil {
+			m.cart.RTC.Latch(value)
+		}
+	default:
+		if !m.ramEnabled {
+			return
+		}
+
+		if m.ramBank >= 0x08 {
+			if m.cart.RTC != nil && m.ramBank <= 0x0C {
+				m.cart.RTC.Write(m.ramBank, value)
+				m.cart.dirty = true
+			}
+			return
+		}
+
+		if len(m.cart.RAM) > 0 {
+			m.cart.writeRAM(m.ramOffset(addr), value)
+		}
+	}
+}
+
+func (m *MBC3) ramOffset(addr uint16) int {
+	return (int(m.ramBank)*0x2000 + int(addr-0xA000)) % len(m.cart.RAM)
+}
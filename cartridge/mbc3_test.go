@@ -0,0 +1,99 @@
+package cartridge
+
+import (
+	"testing"
+
+	"github.com/hmatheisen/gameboy/cpu"
+)
+
+func TestMBC3ROMBanks(t *testing.T) {
+	cart := newTestCartridge(t, makeROM(0x11, 0x06, 0x00))
+
+	tests := []struct {
+		value uint8
+		bank  uint8
+	}{
+		{0x00, 0x01},
+		{0x01, 0x01},
+		{0x20, 0x20},
+		{0x7F, 0x7F},
+		{0x85, 0x05},
+	}
+
+	for _, tt := range tests {
+		cart.Write(0x2000, tt.value)
+		if got := cart.Read(0x4000); got != tt.bank {
+			t.Errorf("ROM bank %02x: want: bank %02x; got bank %02x", tt.value, tt.bank, got)
+		}
+		if got := cart.Read(0x0000); got != 0x00 {
+			t.Errorf("want: bank 00 at 0x0000; got bank %02x", got)
+		}
+	}
+}
+
+func TestMBC3RAMBanks(t *testing.T) {
+	cart := newTestCartridge(t, makeROM(0x13, 0x01, 0x03))
+
+	if cart.Read(0xA000) != 0xFF {
+		t.Errorf("want: disabled RAM = 0xFF; got %02x", cart.Read(0xA000))
+	}
+
+	cart.Write(0x0000, 0x0A)
+	for bank := uint8(0); bank < 4; bank++ {
+		cart.Write(0x4000, bank)
+		cart.Write(0xB000, 0x20+bank)
+	}
+	for bank := uint8(0); bank < 4; bank++ {
+		cart.Write(0x4000, bank)
+		if got := cart.Read(0xB000); got != 0x20+bank {
+			t.Errorf("RAM bank %d: want: %02x; got %02x", bank, 0x20+bank, got)
+		}
+	}
+}
+
+func TestMBC3Timer(t *testing.T) {
+	cart := newTestCartridge(t, makeROM(0x10, 0x01, 0x03))
+	if cart.RTC == nil {
+		t.Fatalf("want: RTC for MBC3+TIMER+RAM+BATTERY")
+	}
+	cart.RTC.Source = RTCEmulated
+
+	cart.Write(0x0000, 0x0A)
+	cart.Write(0x4000, 0x09)
+	cart.Write(0xA000, 42)
+
+	cart.Tick(cpu.CyclesPerSecond * 3)
+
+	cart.Write(0x6000, 0x00)
+	cart.Write(0x6000, 0x01)
+
+	cart.Write(0x4000, 0x08)
+	if got := cart.Read(0xA000); got != 3 {
+		t.Errorf("want: seconds = 3; got %d", got)
+	}
+	cart.Write(0x4000, 0x09)
+	if got := cart.Read(0xA000); got != 42 {
+		t.Errorf("want: minutes = 42; got %d", got)
+	}
+
+	// RAM bank 0 is still there
+	cart.Write(0x4000, 0x00)
+	cart.Write(0xA000, 0x55)
+	if got := cart.Read(0xA000); got != 0x55 {
+		t.Errorf("want: RAM = 0x55; got %02x", got)
+	}
+}
+
+func TestMBC3NoTimer(t *testing.T) {
+	cart := newTestCartridge(t, makeROM(0x13, 0x01, 0x02))
+	if cart.RTC != nil {
+		t.Errorf("want: no RTC for MBC3+RAM+BATTERY")
+	}
+
+	cart.Write(0x0000, 0x0A)
+	cart.Write(0x4000, 0x08)
+	cart.Write(0xA000, 0x12)
+	if got := cart.Read(0xA000); got != 0xFF {
+		t.Errorf("want: 0xFF; got %02x", got)
+	}
+}
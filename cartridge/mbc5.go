@@ -0,0 +1,77 @@
+package cartridge
+
+// MBC5 maps up to 8 MiB of ROM through a 9 bit bank number and up to
+// 128 KiB of RAM. On rumble cartridges bit 3 of the RAM bank register drives
+// the motor instead of selecting RAM.
+type MBC5 struct {
+	cart *Cartridge
+
+	ramEnabled bool
+	romBank    uint16
+	ramBank    uint8
+	rumble     bool
+}
+
+func NewMBC5(cart *Cartridge) *MBC5 {
+	mbc := new(MBC5)
+
+	mbc.cart = cart
+	mbc.romBank = 1
+
+	return mbc
+}
+
+func (m *MBC5) Read(addr uint16) uint8 {
+	switch {
+	case addr < 0x4000:
+		return m.cart.ROM[addr]
+	case addr < 0x8000:
+		bank := int(m.romBank) % (len(m.cart.ROM) / 0x4000)
+		return m.cart.ROM[bank*0x4000+int(addr-0x4000)]
+	}
+
+	if !m.ramEnabled || len(m.cart.RAM) == 0 {
+		return 0xFF
+	}
+	return m.cart.RAM[m.ramOffset(addr)]
+}
+
+func (m *MBC5) Write(addr uint16, value uint8) {
+	switch {
+	case addr < 0x2000:
+		m.ramEnabled = m.cart.setRAMEnabled(m.ramEnabled, value == 0x0A)
+	case addr < 0x3000:
+		// Unlike older MBCs, bank 0 can be mapped at 0x4000
+		m.romBank = m.romBank&0x100 | uint16(value)
+	case addr < 0x4000:
+		m.romBank = uint16(value&0x01)<<8 | m.romBank&0xFF
+	case addr < 0x6000:
+		if m.cart.Header.Type.HasRumble() {
+			m.ramBank = value & 0x07
+			m.setRumble(value&0x08 != 0)
+		} else {
+			m.ramBank = value & 0x0F
+		}
+	case addr < 0x8000:
+	default:
+		if m.ramEnabled && len(m.cart.RAM) > 0 {
+			m.cart.writeRAM(m.ramOffset(addr), value)
+		}
+	}
+}
+
+func (m *MBC5) ramOffset(addr uint16) int {
+	return (int(m.ramBank)*0x2000 + int(addr-0xA000)) % len(m.cart.RAM)
+}
+
+// Only transitions of the motor are reported
+func (m *MBC5) setRumble(on bool) {
+	if on == m.rumble {
+		return
+	}
+	m.rumble = on
+
+	if m.cart.OnRumble != nil {
+		m.cart.OnRumble(on)
+	}
+}
@@ -0,0 +1,98 @@
+package cartridge
+
+import "testing"
+
+func TestMBC5ROMBanks(t *testing.T) {
+	cart := newTestCartridge(t, makeROM(0x19, 0x08, 0x00))
+
+	tests := []struct {
+		low, high uint8
+		bank      int
+	}{
+		{0x00, 0x00, 0x000},
+		{0x01, 0x00, 0x001},
+		{0xFF, 0x00, 0x0FF},
+		{0x00, 0x01, 0x100},
+		{0xFF, 0x01, 0x1FF},
+		{0x23, 0xFE, 0x023},
+	}
+
+	for _, tt := range tests {
+		cart.Write(0x2000, tt.low)
+		cart.Write(0x3000, tt.high)
+
+		got := int(cart.Read(0x4001))<<8 | int(cart.Read(0x4000))
+		if got != tt.bank {
+			t.Errorf("ROMB = %02x %02x: want: bank %03x; got bank %03x", tt.high, tt.low, tt.bank, got)
+		}
+	}
+}
+
+func TestMBC5RAMBanks(t *testing.T) {
+	cart := newTestCartridge(t, makeROM(0x1B, 0x01, 0x04))
+
+	cart.Write(0x0000, 0x0A)
+	for bank := uint8(0); bank < 16; bank++ {
+		cart.Write(0x4000, bank)
+		cart.Write(0xA000, 0x30+bank)
+	}
+	for bank := uint8(0); bank < 16; bank++ {
+		cart.Write(0x4000, bank)
+		if got := cart.Read(0xA000); got != 0x30+bank {
+			t.Errorf("RAM bank %d: want: %02x; got %02x", bank, 0x30+bank, got)
+		}
+	}
+
+	// Only 0x0A enables RAM, upper bits included
+	cart.Write(0x0000, 0x1A)
+	if got := cart.Read(0xA000); got != 0xFF {
+		t.Errorf("want: disabled RAM = 0xFF; got %02x", got)
+	}
+}
+
+func TestMBC5Rumble(t *testing.T) {
+	cart := newTestCartridge(t, makeROM(0x1E, 0x01, 0x03))
+
+	var events []bool
+	cart.OnRumble = func(on bool) { events = append(events, on) }
+
+	cart.Write(0x0000, 0x0A)
+	cart.Write(0x4000, 0x0A)
+	cart.Write(0xA000, 0x42)
+	cart.Write(0x4000, 0x0A)
+	cart.Write(0x4000, 0x02)
+	cart.Write(0x4000, 0x00)
+	cart.Write(0x4000, 0x08)
+
+	want := []bool{true, false, true}
+	if len(events) != len(want) {
+		t.Fatalf("want: events %v; got %v", want, events)
+	}
+	for i := range want {
+		if events[i] != want[i] {
+			t.Errorf("want: events %v; got %v", want, events)
+		}
+	}
+
+	// The motor bit does not select RAM
+	cart.Write(0x4000, 0x0A)
+	if got := cart.Read(0xA000); got != 0x42 {
+		t.Errorf("want: RAM bank 2 = 0x42; got %02x", got)
+	}
+	cart.Write(0x4000, 0x02)
+	if got := cart.Read(0xA000); got != 0x42 {
+		t.Errorf("want: RAM bank 2 = 0x42; got %02x", got)
+	}
+}
+
+func TestMBC5NoRumble(t *testing.T) {
+	cart := newTestCartridge(t, makeROM(0x1B, 0x01, 0x04))
+
+	rumbled := false
+	cart.OnRumble = func(on bool) { rumbled = true }
+
+	cart.Write(0x4000, 0x08)
+	if rumbled {
+		t.Errorf("want: no rumble event on a plain MBC5")
+	}
+}
@@ -0,0 +1,228 @@
+package cartridge
+
+import (
+	"encoding/binary"
+	"fmt"
+	"time"
+
+	"github.com/hmatheisen/gameboy/cpu"
+)
+
+// RTCSource selects what makes the MBC3 clock advance
+type RTCSource int
+
+const (
+	// RTCWallClock follows the host clock, even while the emulator is closed
+	RTCWallClock RTCSource = iota
+	// RTCEmulated advances with emulated machine cycles only
+	RTCEmulated
+)
+
+// RTC is the MBC3 real time clock. Registers are addressed by the value
+// written to the MBC3 RAM bank register: 0x08 seconds, 0x09 minutes,
+// 0x0A hours, 0x0B lower 8 bits of the day counter and 0x0C holding bit 8 of
+// the day counter, the halt flag (bit 6) and the day carry (bit 7).
+type RTC struct {
+	Source RTCSource
+
+	seconds, minutes, hours uint8
+	days                    uint16
+	halted, carry           bool
+
+	latched    [5]uint8
+	latchArmed bool
+
+	// Machine cycles into the current second in emulated mode
+	cycles int
+
+	// When the counters were last brought up to date in wall clock mode
+	last time.Time
+	now  func() time.Time
+}
+
+func NewRTC() *RTC {
+	rtc := new(RTC)
+
+	rtc.Source = RTCWallClock
+	rtc.now = time.Now
+	rtc.last = rtc.now()
+
+	return rtc
+}
+
+func (r *RTC) Tick(cycles int) {
+	if r.Source != RTCEmulated || r.halted {
+		return
+	}
+
+	r.cycles += cycles
+	for r.cycles >= cpu.CyclesPerSecond {
+		r.cycles -= cpu.CyclesPerSecond
+		r.tick()
+	}
+}
+
+// Brings the counters up to date with the host clock
+func (r *RTC) sync() {
+	now := r.now()
+
+	if r.Source != RTCWallClock || r.halted {
+		r.last = now
+		return
+	}
+
+	elapsed := int64(now.Sub(r.last) / time.Second)
+	if elapsed > 0 {
+		r.advance(elapsed)
+		r.last = r.last.Add(time.Duration(elapsed) * time.Second)
+	} else if elapsed < 0 {
+		r.last = now
+	}
+}
+
+// Counters only roll over when they hit their nominal limit, out of range
+// values written by the game wrap around at their bit width instead.
+func (r *RTC) tick() {
+	r.seconds = (r.seconds + 1) & 0x3F
+	if r.seconds != 60 {
+		return
+	}
+	r.seconds = 0
+
+	r.minutes = (r.minutes + 1) & 0x3F
+	if r.minutes != 60 {
+		return
+	}
+	r.minutes = 0
+
+	r.hours = (r.hours + 1) & 0x1F
+	if r.hours != 24 {
+		return
+	}
+	r.hours = 0
+
+	r.days = (r.days + 1) & 0x1FF
+	if r.days == 0 {
+		r.carry = true
+	}
+}
+
+func (r *RTC) advance(seconds int64) {
+	for seconds > 0 && (r.seconds >= 60 || r.minutes >= 60 || r.hours >= 24) {
+		r.tick()
+		seconds--
+	}
+	if seconds == 0 {
+		return
+	}
+
+	total := int64(r.days)*86400 + int64(r.hours)*3600 + int64(r.minutes)*60 + int64(r.seconds) + seconds
+
+	days := total / 86400
+	if days > 0x1FF {
+		r.carry = true
+		days &= 0x1FF
+	}
+
+	r.days = uint16(days)
+	r.hours = uint8(total % 86400 / 3600)
+	r.minutes = uint8(total % 3600 / 60)
+	r.seconds = uint8(total % 60)
+}
+
+func (r *RTC) registers() [5]uint8 {
+	dh := uint8(r.days >> 8)
+	if r.halted {
+		dh |= 0x40
+	}
+	if r.carry {
+		dh |= 0x80
+	}
+
+	return [5]uint8{r.seconds, r.minutes, r.hours, uint8(r.days), dh}
+}
+
+func (r *RTC) setRegisters(regs [5]uint8) {
+	r.seconds = regs[0] & 0x3F
+	r.minutes = regs[1] & 0x3F
+	r.hours = regs[2] & 0x1F
+	r.days = uint16(regs[4]&0x01)<<8 | uint16(regs[3])
+	r.halted = regs[4]&0x40 != 0
+	r.carry = regs[4]&0x80 != 0
+}
+
+// Writing 0x00 then 0x01 copies the live counters to the latched registers
+func (r *RTC) Latch(value uint8) {
+	if r.latchArmed && value == 0x01 {
+		r.sync()
+		r.latched = r.registers()
+	}
+	r.latchArmed = value == 0x00
+}
+
+// Reads return the latched registers, reg being 0x08-0x0C
+func (r *RTC) Read(reg uint8) uint8 {
+	return r.latched[reg-0x08]
+}
+
+// Writes go to the live counters
+func (r *RTC) Write(reg uint8, value uint8) {
+	r.sync()
+
+	regs := r.registers()
+	regs[reg-0x08] = value
+	r.setRegisters(regs)
+
+	if reg == 0x08 {
+		r.cycles = 0
+	}
+
+	r.latched[reg-0x08] = r.registers()[reg-0x08]
+}
+
+// MarshalBinary encodes the clock in the 48 bytes footer most emulators
+// append to .sav files: live then latched registers as little endian 32 bit
+// words, followed by a 64 bit UNIX timestamp.
+func (r *RTC) MarshalBinary() ([]byte, error) {
+	r.sync()
+
+	data := make([]byte, 48)
+	for i, reg := range r.registers() {
+		binary.LittleEndian.PutUint32(data[i*4:], uint32(reg))
+	}
+	for i, reg := range r.latched {
+		binary.LittleEndian.PutUint32(data[20+i*4:], uint32(reg))
+	}
+	binary.LittleEndian.PutUint64(data[40:], uint64(r.last.Unix()))
+
+	return data, nil
+}
+
+// UnmarshalBinary restores a 48 bytes footer, or the older 44 bytes one with
+// a 32 bit timestamp. In wall clock mode the time spent since the footer was
+// written is accounted for.
+func (r *RTC) UnmarshalBinary(data []byte) error {
+	var timestamp int64
+
+	switch len(data) {
+	case 48:
+		timestamp = int64(binary.LittleEndian.Uint64(data[40:]))
+	case 44:
+		timestamp = int64(binary.LittleEndian.Uint32(data[40:]))
+	default:
+		return fmt.Errorf("rtc: footer is %d bytes, want 44 or 48", len(data))
+	}
+
+	var regs [5]uint8
+	for i := range regs {
+		regs[i] = uint8(binary.LittleEndian.Uint32(data[i*4:]))
+		r.latched[i] = uint8(binary.LittleEndian.Uint32(data[20+i*4:]))
+	}
+	r.setRegisters(regs)
+	r.cycles = 0
+
+	r.last = time.Unix(timestamp, 0)
+	r.sync()
+
+	return nil
+}
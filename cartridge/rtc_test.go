@@ -0,0 +1,176 @@
+package cartridge
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hmatheisen/gameboy/cpu"
+)
+
+type fakeClock struct {
+	t time.Time
+}
+
+func (c *fakeClock) now() time.Time { return c.t }
+
+func newTestRTC(source RTCSource) (*RTC, *fakeClock) {
+	clock := &fakeClock{t: time.Unix(1_700_000_000, 0)}
+
+	rtc := NewRTC()
+	rtc.Source = source
+	rtc.now = clock.now
+	rtc.last = clock.t
+
+	return rtc, clock
+}
+
+func latchedRegisters(rtc *RTC) [5]uint8 {
+	rtc.Latch(0x00)
+	rtc.Latch(0x01)
+
+	var regs [5]uint8
+	for i := range regs {
+		regs[i] = rtc.Read(0x08 + uint8(i))
+	}
+	return regs
+}
+
+func TestRTCEmulated(t *testing.T) {
+	rtc, clock := newTestRTC(RTCEmulated)
+
+	rtc.Tick(cpu.CyclesPerSecond*61 - 1)
+	clock.t = clock.t.Add(time.Hour)
+
+	if regs := latchedRegisters(rtc); regs != [5]uint8{0, 1, 0, 0, 0} {
+		t.Errorf("want: 00:01:00; got %v", regs)
+	}
+
+	rtc.Tick(1)
+	if regs := latchedRegisters(rtc); regs != [5]uint8{1, 1, 0, 0, 0} {
+		t.Errorf("want: 00:01:01; got %v", regs)
+	}
+}
+
+func TestRTCWallClock(t *testing.T) {
+	rtc, clock := newTestRTC(RTCWallClock)
+
+	rtc.Tick(cpu.CyclesPerSecond * 10)
+	clock.t = clock.t.Add(26*time.Hour + 3*time.Minute + 4*time.Second + 500*time.Millisecond)
+
+	if regs := latchedRegisters(rtc); regs != [5]uint8{4, 3, 2, 1, 0} {
+		t.Errorf("want: day 1 02:03:04; got %v", regs)
+	}
+
+	// The half second left over is not lost
+	clock.t = clock.t.Add(500 * time.Millisecond)
+	if regs := latchedRegisters(rtc); regs[0] != 5 {
+		t.Errorf("want: 5 seconds; got %d", regs[0])
+	}
+}
+
+func TestRTCLatch(t *testing.T) {
+	rtc, clock := newTestRTC(RTCWallClock)
+
+	latchedRegisters(rtc)
+	clock.t = clock.t.Add(5 * time.Second)
+
+	if rtc.Read(0x08) != 0 {
+		t.Errorf("want: latched seconds = 0; got %d", rtc.Read(0x08))
+	}
+
+	rtc.Latch(0x01)
+	if rtc.Read(0x08) != 0 {
+		t.Errorf("want: no latch without writing 0 first; got %d", rtc.Read(0x08))
+	}
+
+	rtc.Latch(0x00)
+	rtc.Latch(0x01)
+	if rtc.Read(0x08) != 5 {
+		t.Errorf("want: latched seconds = 5; got %d", rtc.Read(0x08))
+	}
+}
+
+func TestRTCDayCarry(t *testing.T) {
+	rtc, clock := newTestRTC(RTCWallClock)
+
+	rtc.Write(0x0B, 0xFF)
+	rtc.Write(0x0C, 0x01)
+	rtc.Write(0x0A, 23)
+	rtc.Write(0x09, 59)
+	rtc.Write(0x08, 59)
+	clock.t = clock.t.Add(time.Second)
+
+	if regs := latchedRegisters(rtc); regs != [5]uint8{0, 0, 0, 0, 0x80} {
+		t.Errorf("want: day 0 with carry; got %v", regs)
+	}
+
+	rtc.Write(0x0C, 0x00)
+	if regs := latchedRegisters(rtc); regs[4] != 0x00 {
+		t.Errorf("want: carry cleared; got %02x", regs[4])
+	}
+}
+
+func TestRTCHalt(t *testing.T) {
+	rtc, clock := newTestRTC(RTCWallClock)
+
+	rtc.Write(0x0C, 0x40)
+	clock.t = clock.t.Add(time.Minute)
+
+	if regs := latchedRegisters(rtc); regs != [5]uint8{0, 0, 0, 0, 0x40} {
+		t.Errorf("want: stopped; got %v", regs)
+	}
+
+	rtc.Write(0x0C, 0x00)
+	clock.t = clock.t.Add(time.Second)
+	if regs := latchedRegisters(rtc); regs[0] != 1 {
+		t.Errorf("want: 1 second after resuming; got %d", regs[0])
+	}
+}
+
+func TestRTCOutOfRange(t *testing.T) {
+	rtc, _ := newTestRTC(RTCEmulated)
+
+	rtc.Write(0x08, 63)
+	rtc.Write(0x09, 59)
+	rtc.Tick(cpu.CyclesPerSecond)
+
+	if regs := latchedRegisters(rtc); regs[0] != 0 || regs[1] != 59 {
+		t.Errorf("want: seconds wrap without carry; got %v", regs)
+	}
+}
+
+func TestRTCFooter(t *testing.T) {
+	rtc, clock := newTestRTC(RTCWallClock)
+	rtc.Write(0x09, 10)
+	latchedRegisters(rtc)
+	rtc.Write(0x0A, 5)
+
+	data, err := rtc.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) != 48 {
+		t.Fatalf("want: 48 bytes; got %d", len(data))
+	}
+	if data[4] != 10 || data[8] != 5 || data[24] != 10 || data[28] != 5 {
+		t.Errorf("want: minutes and hours in footer; got %v", data[:40])
+	}
+
+	restored, _ := newTestRTC(RTCWallClock)
+	clock.t = clock.t.Add(90 * time.Second)
+	restored.now = clock.now
+	if err := restored.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+
+	if rtc.Read(0x09) != restored.Read(0x09) {
+		t.Errorf("want: latched minutes = %d; got %d", rtc.Read(0x09), restored.Read(0x09))
+	}
+	if regs := latchedRegisters(restored); regs != [5]uint8{30, 11, 5, 0, 0} {
+		t.Errorf("want: 05:11:30; got %v", regs)
+	}
+
+	if err := restored.UnmarshalBinary(data[:40]); err == nil {
+		t.Errorf("want: error for short footer")
+	}
+}
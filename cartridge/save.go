@@ -0,0 +1,119 @@
+package cartridge
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/hmatheisen/gameboy/cpu"
+)
+
+// Battery backed RAM is saved at least this often while it keeps changing
+const autosaveCycles = 5 * cpu.CyclesPerSecond
+
+// SaveData returns the content of a .sav file: external RAM, followed by the
+// clock footer on cartridges with an RTC
+func (c *Cartridge) SaveData() []byte {
+	data := make([]byte, len(c.RAM))
+	copy(data, c.RAM)
+
+	if c.RTC != nil {
+		footer, _ := c.RTC.MarshalBinary()
+		data = append(data, footer...)
+	}
+
+	return data
+}
+
+// LoadSaveData restores external RAM, and the clock when the data carries a
+// footer for it. Anything else past the RAM, as left by emulators keeping
+// more in their saves, is ignored with a warning
+func (c *Cartridge) LoadSaveData(data []byte) error {
+	if len(data) < len(c.RAM) {
+		return fmt.Errorf("cartridge: save is %d bytes, want at least %d", len(data), len(c.RAM))
+	}
+
+	footer := data[len(c.RAM):]
+	if len(footer) > 0 {
+		err := fmt.Errorf("save is %d bytes, want %d", len(data), len(c.RAM))
+		if c.RTC != nil {
+			err = c.RTC.UnmarshalBinary(footer)
+		}
+		if err != nil {
+			c.Warnings = append(c.Warnings, fmt.Errorf("cartridge: %w, ignoring the %d bytes past RAM", err, len(footer)))
+		}
+	}
+
+	copy(c.RAM, data)
+	c.dirty = false
+	c.flushPending = false
+
+	return nil
+}
+
+func (c *Cartridge) LoadSaveFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	if err := c.LoadSaveData(data); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	return nil
+}
+
+// WriteSaveFile replaces the file at path with the save data, going through
+// a temporary file so a crash never leaves a half written save behind
+func (c *Cartridge) WriteSaveFile(path string) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(c.SaveData()); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+// Flush saves battery backed RAM to SavePath if it changed
+func (c *Cartridge) Flush() error {
+	if !c.Header.Type.HasBattery() || c.SavePath == "" || !c.dirty {
+		return nil
+	}
+
+	if err := c.WriteSaveFile(c.SavePath); err != nil {
+		return err
+	}
+
+	c.dirty = false
+	c.flushPending = false
+
+	return nil
+}
+
+// AutoSave flushes when the game disabled RAM after writing to it, or when
+// RAM has been changing for a while without being saved
+func (c *Cartridge) AutoSave() error {
+	if !c.flushPending {
+		return nil
+	}
+
+	return c.Flush()
+}
+
+func (c *Cartridge) Close() error {
+	return c.Flush()
+}
@@ -0,0 +1,245 @@
+package cartridge
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeTestROM(t *testing.T, rom []uint8) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "game.gb")
+	if err := os.WriteFile(path, rom, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func TestSaveRoundTrip(t *testing.T) {
+	path := writeTestROM(t, makeROM(0x03, 0x01, 0x02))
+
+	cart, err := Load(path)
+	if err != nil {
+		t.Fatalf("want: no error; got %v", err)
+	}
+	if cart.SavePath != filepath.Join(filepath.Dir(path), "game.sav") {
+		t.Errorf("want: game.sav next to the ROM; got %s", cart.SavePath)
+	}
+
+	cart.Write(0x0000, 0x0A)
+	cart.Write(0xA010, 0x42)
+	if err := cart.Close(); err != nil {
+		t.Fatalf("want: no error; got %v", err)
+	}
+
+	data, err := os.ReadFile(cart.SavePath)
+	if err != nil {
+		t.Fatalf("want: save file; got %v", err)
+	}
+	if len(data) != 8<<10 || data[0x10] != 0x42 {
+		t.Errorf("want: 8 KiB save with 0x42 at 0x10; got %d bytes", len(data))
+	}
+
+	reloaded, err := Load(path)
+	if err != nil {
+		t.Fatalf("want: no error; got %v", err)
+	}
+	reloaded.Write(0x0000, 0x0A)
+	if got := reloaded.Read(0xA010); got != 0x42 {
+		t.Errorf("want: 0x42 restored; got %02x", got)
+	}
+}
+
+func TestSaveWithoutBattery(t *testing.T) {
+	path := writeTestROM(t, makeROM(0x02, 0x01, 0x02))
+
+	cart, err := Load(path)
+	if err != nil {
+		t.Fatalf("want: no error; got %v", err)
+	}
+
+	cart.Write(0x0000, 0x0A)
+	cart.Write(0xA000, 0x42)
+	cart.Write(0x0000, 0x00)
+	if err := cart.Close(); err != nil {
+		t.Fatalf("want: no error; got %v", err)
+	}
+
+	if cart.SavePath != "" {
+		t.Errorf("want: no save path; got %s", cart.SavePath)
+	}
+	if _, err := os.Stat(filepath.Join(filepath.Dir(path), "game.sav")); err == nil {
+		t.Errorf("want: no save file for a cartridge without battery")
+	}
+}
+
+func TestAutoSaveOnRAMDisable(t *testing.T) {
+	path := writeTestROM(t, makeROM(0x1B, 0x01, 0x02))
+
+	cart, err := Load(path)
+	if err != nil {
+		t.Fatalf("want: no error; got %v", err)
+	}
+
+	cart.Write(0x0000, 0x0A)
+	cart.Write(0xA000, 0x99)
+	if err := cart.AutoSave(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(cart.SavePath); err == nil {
+		t.Fatalf("want: no save while RAM is enabled")
+	}
+
+	cart.Write(0x0000, 0x00)
+	if err := cart.AutoSave(); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := os.ReadFile(cart.SavePath); err != nil || data[0] != 0x99 {
+		t.Errorf("want: save written after RAM was disabled; got %v", err)
+	}
+}
+
+func TestAutoSavePeriodic(t *testing.T) {
+	path := writeTestROM(t, makeROM(0x03, 0x01, 0x02))
+
+	cart, err := Load(path)
+	if err != nil {
+		t.Fatalf("want: no error; got %v", err)
+	}
+
+	cart.Write(0x0000, 0x0A)
+	cart.Write(0xA000, 0x77)
+
+	cart.Tick(autosaveCycles - 1)
+	cart.AutoSave()
+	if _, err := os.Stat(cart.SavePath); err == nil {
+		t.Fatalf("want: no save before the autosave period")
+	}
+
+	cart.Tick(1)
+	cart.AutoSave()
+	if data, err := os.ReadFile(cart.SavePath); err != nil || data[0] != 0x77 {
+		t.Errorf("want: save written after the autosave period; got %v", err)
+	}
+}
+
+func TestSaveDataWithRTC(t *testing.T) {
+	cart := newTestCartridge(t, makeROM(0x10, 0x01, 0x02))
+	cart.RTC.Source = RTCEmulated
+
+	cart.Write(0x0000, 0x0A)
+	cart.Write(0xA000, 0x11)
+	cart.Write(0x4000, 0x0A)
+	cart.Write(0xA000, 7)
+
+	data := cart.SaveData()
+	if len(data) != 8<<10+48 {
+		t.Fatalf("want: RAM followed by 48 bytes footer; got %d bytes", len(data))
+	}
+
+	other := newTestCartridge(t, makeROM(0x10, 0x01, 0x02))
+	if err := other.LoadSaveData(data); err != nil {
+		t.Fatalf("want: no error; got %v", err)
+	}
+	if !bytes.Equal(other.SaveData()[:8<<10], cart.RAM) {
+		t.Errorf("want: same RAM")
+	}
+
+	other.Write(0x0000, 0x0A)
+	other.Write(0x4000, 0x0A)
+	other.Write(0x6000, 0x00)
+	other.Write(0x6000, 0x01)
+	if got := other.Read(0xA000); got != 7 {
+		t.Errorf("want: hours = 7; got %d", got)
+	}
+
+	if err := other.LoadSaveData(data[:100]); err == nil {
+		t.Errorf("want: error for short save")
+	}
+
+	// Loaded anyway, going without the clock
+	noClock := newTestCartridge(t, makeROM(0x03, 0x01, 0x02))
+	if err := noClock.LoadSaveData(data); err != nil {
+		t.Errorf("want: no error for a footer on a cartridge without clock; got %v", err)
+	}
+	if len(noClock.Warnings) != 1 {
+		t.Errorf("want: a warning for the footer; got %v", noClock.Warnings)
+	}
+	if !bytes.Equal(noClock.RAM, cart.RAM) {
+		t.Errorf("want: same RAM")
+	}
+}
+
+func TestLoadOversizedSave(t *testing.T) {
+	cart := newTestCartridge(t, makeROM(0x03, 0x01, 0x02))
+
+	data := make([]byte, 32<<10)
+	data[0x10] = 0x42
+	data[8<<10] = 0x99
+	if err := cart.LoadSaveData(data); err != nil {
+		t.Fatalf("want: no error; got %v", err)
+	}
+	if cart.RAM[0x10] != 0x42 {
+		t.Errorf("want: 0x42 restored; got %02x", cart.RAM[0x10])
+	}
+	if len(cart.Warnings) != 1 {
+		t.Errorf("want: a warning for the extra bytes; got %v", cart.Warnings)
+	}
+
+	// A clock footer of no known size leaves the clock alone
+	clock := newTestCartridge(t, makeROM(0x10, 0x01, 0x02))
+	if err := clock.LoadSaveData(data); err != nil {
+		t.Fatalf("want: no error; got %v", err)
+	}
+	if clock.RAM[0x10] != 0x42 || len(clock.Warnings) != 1 {
+		t.Errorf("want: RAM restored with a warning; got %02x, %v", clock.RAM[0x10], clock.Warnings)
+	}
+}
+
+func TestLoadSaveDir(t *testing.T) {
+	path := writeTestROM(t, makeROM(0x03, 0x01, 0x02))
+	dir := t.TempDir()
+
+	save := make([]byte, 8<<10)
+	save[0x10] = 0x42
+	if err := os.WriteFile(filepath.Join(dir, "game.sav"), save, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cart, err := Load(path, WithSaveDir(dir))
+	if err != nil {
+		t.Fatalf("want: no error; got %v", err)
+	}
+	if cart.SavePath != filepath.Join(dir, "game.sav") {
+		t.Errorf("want: game.sav in the save directory; got %s", cart.SavePath)
+	}
+	cart.Write(0x0000, 0x0A)
+	if got := cart.Read(0xA010); got != 0x42 {
+		t.Errorf("want: 0x42 restored; got %02x", got)
+	}
+}
+
+func TestLoadWithoutSave(t *testing.T) {
+	path := writeTestROM(t, makeROM(0x03, 0x01, 0x02))
+
+	// Not even a broken save stops the ROM from loading
+	savePath := filepath.Join(filepath.Dir(path), "game.sav")
+	if err := os.WriteFile(savePath, []byte{0x42}, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cart, err := Load(path, WithoutSave())
+	if err != nil {
+		t.Fatalf("want: no error; got %v", err)
+	}
+	if cart.SavePath != "" {
+		t.Errorf("want: no save path; got %s", cart.SavePath)
+	}
+	cart.Write(0x0000, 0x0A)
+	if got := cart.Read(0xA000); got == 0x42 {
+		t.Errorf("want: blank RAM; got %02x", got)
+	}
+}
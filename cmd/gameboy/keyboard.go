@@ -0,0 +1,105 @@
+package main
+
+import (
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/hmatheisen/gameboy/joypad"
+)
+
+const keysHelp = "keys: arrows or WASD for the pad, X for A, Z for B, Enter for START and Space for SELECT"
+
+var keyButtons = map[string]joypad.Buttons{
+	"\x1b[A": joypad.ButtonUp,
+	"\x1b[B": joypad.ButtonDown,
+	"\x1b[C": joypad.ButtonRight,
+	"\x1b[D": joypad.ButtonLeft,
+	"w":      joypad.ButtonUp,
+	"s":      joypad.ButtonDown,
+	"d":      joypad.ButtonRight,
+	"a":      joypad.ButtonLeft,
+	"x":      joypad.ButtonA,
+	"z":      joypad.ButtonB,
+	"\r":     joypad.ButtonStart,
+	"\n":     joypad.ButtonStart,
+	" ":      joypad.ButtonSelect,
+}
+
+// Terminals report keys as they are typed but never their release, a button
+// stays pressed until its key was not seen for keyHold. Auto repeat keeps
+// sending a key held down
+const keyHold = 200 * time.Millisecond
+
+// Puts the terminal in a mode where keys are read as soon as they are typed
+// and not echoed, returning a function restoring the previous mode
+func rawTerminal(f *os.File) (restore func(), err error) {
+	state, err := stty(f, "-g")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := stty(f, "-icanon", "-echo", "min", "1"); err != nil {
+		return nil, err
+	}
+
+	return func() {
+		stty(f, strings.TrimSpace(state))
+	}, nil
+}
+
+func stty(f *os.File, args ...string) (string, error) {
+	cmd := exec.Command("stty", args...)
+	cmd.Stdin = f
+	out, err := cmd.Output()
+	return string(out), err
+}
+
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// Presses the buttons bound to the keys read from r, until reading fails
+func readKeys(r io.Reader, input *joypad.ManualInput) {
+	releases := make(map[joypad.Buttons]*time.Timer)
+
+	buf := make([]byte, 64)
+	for {
+		n, err := r.Read(buf)
+		if err != nil {
+			return
+		}
+
+		for _, key := range splitKeys(buf[:n]) {
+			buttons, ok := keyButtons[strings.ToLower(key)]
+			if !ok {
+				continue
+			}
+
+			if release, ok := releases[buttons]; ok {
+				release.Reset(keyHold)
+			} else {
+				releases[buttons] = time.AfterFunc(keyHold, func() {
+					input.Release(buttons)
+				})
+			}
+			input.Press(buttons)
+		}
+	}
+}
+
+// Splits what the terminal sent into keys, arrows come as ESC [ and a letter
+func splitKeys(data []byte) []string {
+	var keys []string
+	for i := 0; i < len(data); i++ {
+		if data[i] == 0x1B && i+2 < len(data) && data[i+1] == '[' {
+			keys = append(keys, string(data[i:i+3]))
+			i += 2
+			continue
+		}
+		keys = append(keys, string(data[i]))
+	}
+	return keys
+}
@@ -0,0 +1,172 @@
+package cpu
+
+func (c *CPU) inc(val uint8) uint8 {
+	result := val + 1
+
+	c.SetZFlag(result == 0)
+	c.SetNFlag(false)
+	c.SetHFlag((val&0xF)+(1&0xF) > 0xF)
+
+	return result
+}
+
+func (c *CPU) dec(val uint8) uint8 {
+	result := val - 1
+
+	c.SetZFlag(result == 0)
+	c.SetNFlag(true)
+	c.SetHFlag(val&0x0F == 0)
+
+	return result
+}
+
+// H and C are the carries out of bit 3 and bit 7
+func (c *CPU) add(val1, val2 uint8, carry uint8) uint8 {
+	result := uint16(val1) + uint16(val2) + uint16(carry)
+
+	c.SetZFlag(uint8(result) == 0)
+	c.SetNFlag(false)
+	c.SetHFlag((val1&0xF)+(val2&0xF)+carry > 0xF)
+	c.SetCFlag(result > 0xFF)
+
+	return uint8(result)
+}
+
+// H and C are the borrows into bit 3 and bit 7
+func (c *CPU) sub(val1, val2 uint8, borrow uint8) uint8 {
+	result := val1 - val2 - borrow
+
+	c.SetZFlag(result == 0)
+	c.SetNFlag(true)
+	c.SetHFlag(val1&0xF < val2&0xF+borrow)
+	c.SetCFlag(uint16(val1) < uint16(val2)+uint16(borrow))
+
+	return result
+}
+
+func (c *CPU) and(val1, val2 uint8) uint8 {
+	result := val1 & val2
+
+	c.SetZFlag(result == 0)
+	c.SetNFlag(false)
+	c.SetHFlag(true)
+	c.SetCFlag(false)
+
+	return result
+}
+
+func (c *CPU) xor(val1, val2 uint8) uint8 {
+	result := val1 ^ val2
+
+	c.SetZFlag(result == 0)
+	c.SetNFlag(false)
+	c.SetHFlag(false)
+	c.SetCFlag(false)
+
+	return result
+}
+
+func (c *CPU) or(val1, val2 uint8) uint8 {
+	result := val1 | val2
+
+	c.SetZFlag(result == 0)
+	c.SetNFlag(false)
+	c.SetHFlag(false)
+	c.SetCFlag(false)
+
+	return result
+}
+
+// CP is a SUB which only keeps the flags
+func (c *CPU) cp(val1, val2 uint8) {
+	c.sub(val1, val2, 0)
+}
+
+func (c *CPU) carryBit() uint8 {
+	if c.CFlag() {
+		return 1
+	}
+	return 0
+}
+
+// Rotations go through the carry flag when carry is set, otherwise the bit
+// shifted out is copied back in on the other side.
+func (c *CPU) rl(val uint8, carry bool) uint8 {
+	in := val >> 7
+	if carry {
+		in = c.carryBit()
+	}
+	result := val<<1 | in
+
+	c.SetZFlag(result == 0)
+	c.SetNFlag(false)
+	c.SetHFlag(false)
+	c.SetCFlag(val&0x80 == 0x80)
+
+	return result
+}
+
+func (c *CPU) rr(val uint8, carry bool) uint8 {
+	in := val << 7
+	if carry {
+		in = c.carryBit() << 7
+	}
+	result := val>>1 | in
+
+	c.SetZFlag(result == 0)
+	c.SetNFlag(false)
+	c.SetHFlag(false)
+	c.SetCFlag(val&0x01 == 0x01)
+
+	return result
+}
+
+func (c *CPU) sla(val uint8) uint8 {
+	result := val << 1
+
+	c.SetZFlag(result == 0)
+	c.SetNFlag(false)
+	c.SetHFlag(false)
+	c.SetCFlag(val&0x80 == 0x80)
+
+	return result
+}
+
+func (c *CPU) sra(val uint8) uint8 {
+	result := val>>1 | val&0x80
+
+	c.SetZFlag(result == 0)
+	c.SetNFlag(false)
+	c.SetHFlag(false)
+	c.SetCFlag(val&0x01 == 0x01)
+
+	return result
+}
+
+func (c *CPU) srl(val uint8) uint8 {
+	result := val >> 1
+
+	c.SetZFlag(result == 0)
+	c.SetNFlag(false)
+	c.SetHFlag(false)
+	c.SetCFlag(val&0x01 == 0x01)
+
+	return result
+}
+
+func (c *CPU) swap(val uint8) uint8 {
+	result := val<<4 | val>>4
+
+	c.SetZFlag(result == 0)
+	c.SetNFlag(false)
+	c.SetHFlag(false)
+	c.SetCFlag(false)
+
+	return result
+}
+
+func (c *CPU) bit(n uint8, val uint8) {
+	c.SetZFlag(val&(1<<n) == 0)
+	c.SetNFlag(false)
+	c.SetHFlag(true)
+}
@@ -1,11 +1,9 @@
-package main
+package cpu
 
-import (
-	"testing"
-)
+import "testing"
 
 func TestInc(t *testing.T) {
-	cpu := NewCPU()
+	cpu := New(nil, nil)
 	cpu.B = 12
 
 	if cpu.inc(cpu.B) != 13 {
@@ -24,7 +22,7 @@ func TestInc(t *testing.T) {
 }
 
 func TestDec(t *testing.T) {
-	cpu := NewCPU()
+	cpu := New(nil, nil)
 	cpu.B = 12
 
 	if cpu.dec(cpu.B) != 11 {
@@ -34,8 +32,8 @@ func TestDec(t *testing.T) {
 	if cpu.ZFlag() != false {
 		t.Errorf("want: Z = false; got Z = %t", cpu.ZFlag())
 	}
-	if cpu.NFlag() != false {
-		t.Errorf("want: N = false; got N = %t", cpu.NFlag())
+	if cpu.NFlag() != true {
+		t.Errorf("want: N = true; got N = %t", cpu.NFlag())
 	}
 	if cpu.HFlag() != false {
 		t.Errorf("want: H = false; got H = %t", cpu.HFlag())
@@ -43,7 +41,7 @@ func TestDec(t *testing.T) {
 }
 
 func TestAdd(t *testing.T) {
-	cpu := NewCPU()
+	cpu := New(nil, nil)
 	cpu.A = 10
 	cpu.B = 12
 
@@ -68,7 +66,7 @@ func TestAdd(t *testing.T) {
 }
 
 func TestSub(t *testing.T) {
-	cpu := NewCPU()
+	cpu := New(nil, nil)
 	cpu.A = 12
 	cpu.B = 10
 
@@ -93,7 +91,7 @@ func TestSub(t *testing.T) {
 }
 
 func TestXOR(t *testing.T) {
-	cpu := NewCPU()
+	cpu := New(nil, nil)
 	cpu.A = 10
 	cpu.B = 12
 
@@ -118,7 +116,7 @@ func TestXOR(t *testing.T) {
 }
 
 func TestOr(t *testing.T) {
-	cpu := NewCPU()
+	cpu := New(nil, nil)
 	cpu.A = 10
 	cpu.B = 12
 
@@ -143,22 +141,45 @@ func TestOr(t *testing.T) {
 }
 
 func TestCP(t *testing.T) {
-	cpu := NewCPU()
-	cpu.A = 10
-	cpu.B = 0
+	tests := []struct {
+		a, n    uint8
+		z, h, c bool
+	}{
+		{10, 0, false, false, false},
+		{10, 10, true, false, false},
+		{0x10, 0x01, false, true, false},
+		{0x10, 0x20, false, false, true},
+		{0x00, 0x01, false, true, true},
+	}
+
+	for _, tt := range tests {
+		cpu := New(nil, nil)
+		cpu.A = tt.a
+
+		cpu.cp(cpu.A, tt.n)
+
+		if cpu.A != tt.a {
+			t.Errorf("CP %02x, %02x: want: A = %02x; got A = %02x", tt.a, tt.n, tt.a, cpu.A)
+		}
+		if cpu.ZFlag() != tt.z || !cpu.NFlag() || cpu.HFlag() != tt.h || cpu.CFlag() != tt.c {
+			t.Errorf("CP %02x, %02x: want: Z = %t, N = true, H = %t, C = %t; got F = %08b", tt.a, tt.n, tt.z, tt.h, tt.c, cpu.F)
+		}
+	}
+}
 
-	cpu.cp(cpu.A, cpu.B)
+func TestCarryIn(t *testing.T) {
+	cpu := New(nil, nil)
 
-	if cpu.ZFlag() != false {
-		t.Errorf("want: Z = false; got Z = %t", cpu.ZFlag())
+	if got := cpu.add(0x0F, 0x00, 1); got != 0x10 || !cpu.HFlag() || cpu.CFlag() {
+		t.Errorf("ADC 0f, 00: want: 10, H; got %02x, F = %08b", got, cpu.F)
 	}
-	if cpu.NFlag() != true {
-		t.Errorf("want: N = true; got N = %t", cpu.NFlag())
+	if got := cpu.add(0xFF, 0x00, 1); got != 0x00 || !cpu.ZFlag() || !cpu.CFlag() {
+		t.Errorf("ADC ff, 00: want: 00, Z and C; got %02x, F = %08b", got, cpu.F)
 	}
-	if cpu.HFlag() != true {
-		t.Errorf("want: H = true; got H = %t", cpu.HFlag())
+	if got := cpu.sub(0x10, 0x00, 1); got != 0x0F || !cpu.HFlag() || cpu.CFlag() {
+		t.Errorf("SBC 10, 00: want: 0f, H; got %02x, F = %08b", got, cpu.F)
 	}
-	if cpu.CFlag() != true {
-		t.Errorf("want: C = true; got C = %t", cpu.CFlag())
+	if got := cpu.sub(0x00, 0xFF, 1); got != 0x00 || !cpu.ZFlag() || !cpu.CFlag() {
+		t.Errorf("SBC 00, ff: want: 00, Z and C; got %02x, F = %08b", got, cpu.F)
 	}
 }
@@ -0,0 +1,284 @@
+// Package cpu emulates the SM83, the Game Boy processor
+package cpu
+
+import "github.com/hmatheisen/gameboy/interrupt"
+
+// Machine cycles per second
+const CyclesPerSecond = 1 << 20
+
+// Bus is how the CPU reaches the rest of the system. Every read and write
+// takes a machine cycle, Tick lets one go by without a memory access
+type Bus interface {
+	Read(addr uint16) uint8
+	Write(addr uint16, value uint8)
+	Tick()
+}
+
+type OPCode uint8
+
+type CPU struct {
+	A, F uint8
+	B, C uint8
+	D, E uint8
+	H, L uint8
+	PC   uint16
+	SP   uint16
+	IME  bool
+	Halt bool
+
+	// EI only sets IME after the instruction following it
+	IMEPending bool
+
+	// Set when HALT is executed with IME off and an interrupt already
+	// pending: the CPU does not halt and fails to increment PC on the next
+	// fetch, so the following byte is read twice.
+	HaltBug bool
+
+	// Illegal opcodes hang the CPU until reset
+	Locked bool
+
+	// Reported by every step once an illegal opcode locked up the CPU
+	lockup error
+
+	// Trace, when set, gets the address of every instruction about to run
+	Trace func(pc uint16)
+
+	// OnBreakpoint, when set, is called on LD B,B. The instruction does
+	// nothing otherwise, which makes it the software breakpoint of
+	// emulators, used by test ROMs such as Mooneye's to report their result
+	OnBreakpoint func()
+
+	bus        Bus
+	interrupts *interrupt.Interrupts
+}
+
+func New(bus Bus, interrupts *interrupt.Interrupts) *CPU {
+	cpu := new(CPU)
+
+	cpu.bus = bus
+	cpu.interrupts = interrupts
+	cpu.Reset()
+
+	return cpu
+}
+
+// Reset puts the registers back as the boot ROM leaves them
+func (c *CPU) Reset() {
+	c.PC = 0x100
+	c.SP = 0xFFFE
+	c.IME = false
+	c.IMEPending = false
+	c.Halt = false
+	c.HaltBug = false
+	c.Locked = false
+	c.lockup = nil
+	c.A = 0x01
+	c.F = 0xB0
+	c.B = 0x00
+	c.C = 0x13
+	c.D = 0x00
+	c.E = 0xD8
+	c.H = 0x01
+	c.L = 0x4D
+}
+
+// Getters for registers as 16 bits pair
+func (c CPU) AF() uint16 { return uint16(c.A)<<8 | uint16(c.F) }
+func (c CPU) BC() uint16 { return uint16(c.B)<<8 | uint16(c.C) }
+func (c CPU) DE() uint16 { return uint16(c.D)<<8 | uint16(c.E) }
+func (c CPU) HL() uint16 { return uint16(c.H)<<8 | uint16(c.L) }
+
+func uint16ToHiLo(value uint16) (Hi uint8, Lo uint8) {
+	Hi = uint8(value >> 8)
+	Lo = uint8(value & 0x00FF)
+	return
+}
+
+// Setters for 16 bits registers
+func (c *CPU) SetAF(value uint16) { c.A, c.F = uint16ToHiLo(value) }
+func (c *CPU) SetBC(value uint16) { c.B, c.C = uint16ToHiLo(value) }
+func (c *CPU) SetDE(value uint16) { c.D, c.E = uint16ToHiLo(value) }
+func (c *CPU) SetHL(value uint16) { c.H, c.L = uint16ToHiLo(value) }
+
+// Flags in the upper nibble of the F register, the lower one always reads 0
+func (c *CPU) setFlag(on bool, pos int) {
+	if on {
+		c.F |= (1 << pos)
+	} else {
+		c.F &= ^(1 << pos)
+	}
+}
+
+func (c *CPU) getFlag(pos int) bool {
+	return c.F>>pos&1 == 1
+}
+
+func (c *CPU) SetZFlag(on bool) { c.setFlag(on, 7) }
+func (c *CPU) SetNFlag(on bool) { c.setFlag(on, 6) }
+func (c *CPU) SetHFlag(on bool) { c.setFlag(on, 5) }
+func (c *CPU) SetCFlag(on bool) { c.setFlag(on, 4) }
+
+func (c *CPU) ZFlag() bool { return c.getFlag(7) }
+func (c *CPU) NFlag() bool { return c.getFlag(6) }
+func (c *CPU) HFlag() bool { return c.getFlag(5) }
+func (c *CPU) CFlag() bool { return c.getFlag(4) }
+
+func (c *CPU) read(addr uint16) uint8 {
+	return c.bus.Read(addr)
+}
+
+func (c *CPU) write(addr uint16, value uint8) {
+	c.bus.Write(addr, value)
+}
+
+func (c *CPU) readPC() uint8 {
+	value := c.read(c.PC)
+
+	c.PC++
+
+	return value
+}
+
+func (c *CPU) readSP() uint8 {
+	value := c.read(c.SP)
+
+	c.SP++
+
+	return value
+}
+
+// 16 bit values are stored low byte first
+func (c *CPU) readPC16() uint16 {
+	lsb := uint16(c.readPC())
+	msb := uint16(c.readPC())
+
+	return msb<<8 | lsb
+}
+
+func (c *CPU) push(value uint16) {
+	c.SP -= 1
+	c.write(c.SP, uint8(value>>8))
+	c.SP -= 1
+	c.write(c.SP, uint8(value&0xFF))
+}
+
+func (c *CPU) pop() uint16 {
+	lsb := uint16(c.readSP())
+	msb := uint16(c.readSP())
+
+	return msb<<8 | lsb
+}
+
+func (c *CPU) Fetch() OPCode {
+	if c.HaltBug {
+		c.HaltBug = false
+		return OPCode(c.read(c.PC))
+	}
+
+	code := c.readPC()
+
+	return OPCode(code)
+}
+
+// Execute runs an opcode once it is fetched, reading the operands following
+// it
+func (c *CPU) Execute(opCode OPCode) {
+	opcodes[opCode].execute(c, uint8(opCode))
+}
+
+// The low three bits of most opcodes select an 8 bit register, 6 being [HL]
+func (c *CPU) readR8(index uint8) uint8 {
+	switch index {
+	case 0:
+		return c.B
+	case 1:
+		return c.C
+	case 2:
+		return c.D
+	case 3:
+		return c.E
+	case 4:
+		return c.H
+	case 5:
+		return c.L
+	case 6:
+		return c.read(c.HL())
+	default:
+		return c.A
+	}
+}
+
+func (c *CPU) writeR8(index uint8, value uint8) {
+	switch index {
+	case 0:
+		c.B = value
+	case 1:
+		c.C = value
+	case 2:
+		c.D = value
+	case 3:
+		c.E = value
+	case 4:
+		c.H = value
+	case 5:
+		c.L = value
+	case 6:
+		c.write(c.HL(), value)
+	default:
+		c.A = value
+	}
+}
+
+// Bits 4 and 5 of 16 bit opcodes select BC, DE, HL or SP
+func (c *CPU) readR16(index uint8) uint16 {
+	switch index {
+	case 0:
+		return c.BC()
+	case 1:
+		return c.DE()
+	case 2:
+		return c.HL()
+	default:
+		return c.SP
+	}
+}
+
+func (c *CPU) writeR16(index uint8, value uint16) {
+	switch index {
+	case 0:
+		c.SetBC(value)
+	case 1:
+		c.SetDE(value)
+	case 2:
+		c.SetHL(value)
+	default:
+		c.SP = value
+	}
+}
+
+// Bits 3 and 4 of conditional opcodes select NZ, Z, NC or C
+func (c *CPU) condition(op uint8) bool {
+	switch op >> 3 & 0x03 {
+	case 0:
+		return !c.ZFlag()
+	case 1:
+		return c.ZFlag()
+	case 2:
+		return !c.CFlag()
+	default:
+		return c.CFlag()
+	}
+}
+
+// SP plus a signed offset, with H and C set from the unsigned addition of
+// the low byte
+func (c *CPU) addSP(e int8) uint16 {
+	offset := uint16(e)
+
+	c.SetZFlag(false)
+	c.SetNFlag(false)
+	c.SetHFlag((c.SP&0xF)+(offset&0xF) > 0xF)
+	c.SetCFlag((c.SP&0xFF)+(offset&0xFF) > 0xFF)
+
+	return c.SP + offset
+}
@@ -0,0 +1,505 @@
+package cpu
+
+import (
+	"testing"
+
+	"github.com/hmatheisen/gameboy/bus"
+	"github.com/hmatheisen/gameboy/interrupt"
+)
+
+func TestGetters(t *testing.T) {
+	cpu := New(nil, nil)
+
+	cpu.A, cpu.F = 0x00, 0x11
+	cpu.B, cpu.C = 0x11, 0x00
+	cpu.D, cpu.E = 0x10, 0x01
+	cpu.H, cpu.L = 0x01, 0x10
+
+	if cpu.AF() != 0x0011 {
+		t.Errorf("want: AF = 0x0011; got AF = %x", cpu.AF())
+	}
+	if cpu.BC() != 0x1100 {
+		t.Errorf("want: BC = 0x1100; got BC = %x", cpu.BC())
+	}
+	if cpu.DE() != 0x1001 {
+		t.Errorf("want: DE = 0x1001; got DE = %x", cpu.DE())
+	}
+	if cpu.HL() != 0x0110 {
+		t.Errorf("want: HL = 0x0110; got HL = %x", cpu.HL())
+	}
+}
+
+func TestSetters(t *testing.T) {
+	cpu := New(nil, nil)
+
+	cpu.SetAF(0x0011)
+	cpu.SetBC(0x1100)
+	cpu.SetDE(0x1001)
+	cpu.SetHL(0x0110)
+
+	if cpu.A != 0x00 {
+		t.Errorf("want: A = 0x00; got A = %x", cpu.A)
+	}
+	if cpu.F != 0x11 {
+		t.Errorf("want: F = 0x11; got F = %x", cpu.F)
+	}
+	if cpu.B != 0x11 {
+		t.Errorf("want: B = 0x11; got B = %x", cpu.B)
+	}
+	if cpu.C != 0x00 {
+		t.Errorf("want: C = 0x00; got C = %x", cpu.C)
+	}
+	if cpu.D != 0x10 {
+		t.Errorf("want: D = 0x10; got D = %x", cpu.D)
+	}
+	if cpu.E != 0x01 {
+		t.Errorf("want: E = 0x01; got E = %x", cpu.E)
+	}
+	if cpu.H != 0x01 {
+		t.Errorf("want: H = 0x01; got H = %x", cpu.H)
+	}
+	if cpu.L != 0x10 {
+		t.Errorf("want: L = 0x10; got L = %x", cpu.L)
+	}
+}
+
+func TestFlagGetters(t *testing.T) {
+	cpu := New(nil, nil)
+
+	cpu.F = 0b00000000
+	if cpu.ZFlag() {
+		t.Errorf("want: Z = false; got Z = %t", cpu.ZFlag())
+	}
+
+	cpu.F = 0b10000000
+	if !cpu.ZFlag() {
+		t.Errorf("want: Z = true; got Z = %t", cpu.ZFlag())
+	}
+
+	cpu.F = 0b01000000
+	if !cpu.NFlag() {
+		t.Errorf("want: N = true; got N = %t", cpu.NFlag())
+	}
+
+	cpu.F = 0b00100000
+	if !cpu.HFlag() {
+		t.Errorf("want: H = true; got H = %t", cpu.HFlag())
+	}
+
+	cpu.F = 0b00010000
+	if !cpu.CFlag() {
+		t.Errorf("want: C = true; got C = %t", cpu.CFlag())
+	}
+
+	// The lower nibble holds no flag
+	cpu.F = 0b00001111
+	if cpu.ZFlag() || cpu.NFlag() || cpu.HFlag() || cpu.CFlag() {
+		t.Errorf("want: no flag set; got F = %08b", cpu.F)
+	}
+}
+
+func TestFlagSetters(t *testing.T) {
+	cpu := New(nil, nil)
+	cpu.F = 0b00000000
+
+	cpu.SetZFlag(true)
+	if cpu.F != 0b10000000 {
+		t.Errorf("want F = 0b10000000; got F = %08b", cpu.F)
+	}
+	cpu.SetZFlag(false)
+
+	cpu.SetNFlag(true)
+	if cpu.F != 0b01000000 {
+		t.Errorf("want F = 0b01000000; got F = %08b", cpu.F)
+	}
+	cpu.SetNFlag(false)
+
+	cpu.SetHFlag(true)
+	if cpu.F != 0b00100000 {
+		t.Errorf("want F = 0b00100000; got F = %08b", cpu.F)
+	}
+	cpu.SetHFlag(false)
+
+	cpu.SetCFlag(true)
+	if cpu.F != 0b00010000 {
+		t.Errorf("want F = 0b00010000; got F = %08b", cpu.F)
+	}
+	cpu.SetCFlag(false)
+
+	if cpu.F != 0b00000000 {
+		t.Errorf("want F = 0b00000000; got F = %08b", cpu.F)
+	}
+
+	cpu.F = 0b00000000
+	cpu.SetZFlag(false)
+	if cpu.F != 0b00000000 {
+		t.Errorf("want F = 0b00000000; got F = %08b", cpu.F)
+	}
+}
+
+// testMachine runs a CPU on 64 KiB of RAM with the interrupt registers
+// mapped, counting the machine cycles it takes
+type testMachine struct {
+	CPU        *CPU
+	Bus        *bus.MemoryBus
+	Interrupts *interrupt.Interrupts
+	MCycles    int
+}
+
+func newTestMachine() *testMachine {
+	m := new(testMachine)
+
+	m.Interrupts = interrupt.New()
+	m.Bus = bus.NewMemoryBus()
+	m.Bus.Map(0x0000, 0xFFFF, bus.NewRAM(0x0000, 0x10000))
+	m.Bus.Map(0xFF0F, 0xFF0F, m.Interrupts)
+	m.Bus.Map(0xFFFF, 0xFFFF, m.Interrupts)
+	m.CPU = New(m, m.Interrupts)
+
+	return m
+}
+
+func (m *testMachine) Read(addr uint16) uint8 {
+	value := m.Bus.Read(addr)
+	m.Tick()
+	return value
+}
+
+func (m *testMachine) Write(addr uint16, value uint8) {
+	m.Bus.Write(addr, value)
+	m.Tick()
+}
+
+func (m *testMachine) Tick() {
+	m.MCycles++
+}
+
+func (m *testMachine) step() error {
+	return m.CPU.Step()
+}
+
+func loadProgram(m *testMachine, addr uint16, program ...uint8) {
+	for i, b := range program {
+		m.Bus.Write(addr+uint16(i), b)
+	}
+}
+
+type cbTest struct {
+	name    string
+	op      uint8
+	in      uint8
+	carry   bool
+	want    uint8
+	z, h, c bool
+}
+
+func runCBTests(t *testing.T, tests []cbTest) {
+	t.Helper()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestMachine()
+			m.CPU.PC = 0xC000
+			m.CPU.SetHL(0xD000)
+			m.CPU.F = 0
+			m.CPU.SetCFlag(tt.carry)
+			m.CPU.writeR8(tt.op&0x07, tt.in)
+			loadProgram(m, 0xC000, 0xCB, tt.op)
+			m.MCycles = 0
+
+			m.CPU.Execute(m.CPU.Fetch())
+
+			if got := m.CPU.readR8(tt.op & 0x07); got != tt.want {
+				t.Errorf("want: result = %08b; got result = %08b", tt.want, got)
+			}
+			if m.CPU.ZFlag() != tt.z {
+				t.Errorf("want: Z = %t; got Z = %t", tt.z, m.CPU.ZFlag())
+			}
+			if m.CPU.NFlag() {
+				t.Errorf("want: N = false; got N = %t", m.CPU.NFlag())
+			}
+			if m.CPU.HFlag() != tt.h {
+				t.Errorf("want: H = %t; got H = %t", tt.h, m.CPU.HFlag())
+			}
+			if m.CPU.CFlag() != tt.c {
+				t.Errorf("want: C = %t; got C = %t", tt.c, m.CPU.CFlag())
+			}
+			if m.CPU.PC != 0xC002 {
+				t.Errorf("want: PC = 0xC002; got PC = %x", m.CPU.PC)
+			}
+		})
+	}
+}
+
+func TestCBRotates(t *testing.T) {
+	runCBTests(t, []cbTest{
+		{name: "RLC B", op: 0x00, in: 0b10000101, want: 0b00001011, c: true},
+		{name: "RLC C zero", op: 0x01, in: 0x00, want: 0x00, z: true},
+		{name: "RRC D", op: 0x0A, in: 0b00000011, want: 0b10000001, c: true},
+		{name: "RRC A", op: 0x0F, in: 0b00000010, want: 0b00000001},
+		{name: "RL E through carry", op: 0x13, in: 0b10000000, carry: true, want: 0b00000001, c: true},
+		{name: "RL H without carry", op: 0x14, in: 0b10000000, want: 0x00, z: true, c: true},
+		{name: "RR L through carry", op: 0x1D, in: 0b00000001, carry: true, want: 0b10000000, c: true},
+		{name: "RR [HL]", op: 0x1E, in: 0b00000010, want: 0b00000001},
+	})
+}
+
+func TestCBShifts(t *testing.T) {
+	runCBTests(t, []cbTest{
+		{name: "SLA B", op: 0x20, in: 0b11000001, want: 0b10000010, c: true},
+		{name: "SLA [HL] zero", op: 0x26, in: 0b10000000, want: 0x00, z: true, c: true},
+		{name: "SRA C keeps sign", op: 0x29, in: 0b10000011, want: 0b11000001, c: true},
+		{name: "SRA A", op: 0x2F, in: 0b01000000, want: 0b00100000},
+		{name: "SWAP D", op: 0x32, in: 0xAB, carry: true, want: 0xBA},
+		{name: "SWAP [HL] zero", op: 0x36, in: 0x00, want: 0x00, z: true},
+		{name: "SRL E", op: 0x3B, in: 0b10000001, want: 0b01000000, c: true},
+		{name: "SRL A zero", op: 0x3F, in: 0b00000001, want: 0x00, z: true, c: true},
+	})
+}
+
+func TestCBBit(t *testing.T) {
+	runCBTests(t, []cbTest{
+		{name: "BIT 0, B set", op: 0x40, in: 0b00000001, want: 0b00000001, h: true},
+		{name: "BIT 0, B clear", op: 0x40, in: 0b11111110, want: 0b11111110, z: true, h: true},
+		{name: "BIT 7, A keeps carry", op: 0x7F, in: 0b10000000, carry: true, want: 0b10000000, h: true, c: true},
+		{name: "BIT 3, [HL]", op: 0x5E, in: 0b11110111, want: 0b11110111, z: true, h: true},
+	})
+}
+
+func TestCBResSet(t *testing.T) {
+	runCBTests(t, []cbTest{
+		{name: "RES 0, B", op: 0x80, in: 0xFF, want: 0xFE},
+		{name: "RES 7, [HL]", op: 0xBE, in: 0xFF, want: 0x7F},
+		{name: "RES 4, A keeps carry", op: 0xA7, in: 0x10, carry: true, want: 0x00, c: true},
+		{name: "SET 0, C", op: 0xC1, in: 0x00, want: 0x01},
+		{name: "SET 7, [HL]", op: 0xFE, in: 0x00, want: 0x80},
+		{name: "SET 5, L", op: 0xED, in: 0x01, want: 0x21},
+	})
+}
+
+func TestCBCycles(t *testing.T) {
+	tests := []struct {
+		name   string
+		op     uint8
+		cycles int
+	}{
+		{"RLC B", 0x00, 2},
+		{"RLC [HL]", 0x06, 4},
+		{"BIT 0, A", 0x47, 2},
+		{"BIT 0, [HL]", 0x46, 3},
+		{"RES 0, [HL]", 0x86, 4},
+		{"SET 0, [HL]", 0xC6, 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestMachine()
+			m.CPU.PC = 0xC000
+			m.CPU.SetHL(0xD000)
+			loadProgram(m, 0xC000, 0xCB, tt.op)
+
+			m.CPU.Execute(m.CPU.Fetch())
+
+			if m.MCycles != tt.cycles {
+				t.Errorf("want: %d cycles; got %d cycles", tt.cycles, m.MCycles)
+			}
+		})
+	}
+}
+
+func TestImmediateByteOrder(t *testing.T) {
+	m := newTestMachine()
+	m.CPU.PC = 0xC000
+	m.CPU.SP = 0xD000
+	// CALL $C123; at $C123: LD HL, $1234; RET
+	loadProgram(m, 0xC000, 0xCD, 0x23, 0xC1)
+	loadProgram(m, 0xC123, 0x21, 0x34, 0x12, 0xC9)
+
+	m.step()
+	if m.CPU.PC != 0xC123 {
+		t.Errorf("CALL: want: PC = c123; got %04x", m.CPU.PC)
+	}
+	m.step()
+	if m.CPU.HL() != 0x1234 {
+		t.Errorf("LD HL: want: HL = 1234; got %04x", m.CPU.HL())
+	}
+	m.step()
+	if m.CPU.PC != 0xC003 {
+		t.Errorf("RET: want: PC = c003; got %04x", m.CPU.PC)
+	}
+}
+
+func TestJRBackwards(t *testing.T) {
+	m := newTestMachine()
+	m.CPU.PC = 0xC000
+	// JR -2 loops on itself
+	loadProgram(m, 0xC000, 0x18, 0xFE)
+
+	m.step()
+
+	if m.CPU.PC != 0xC000 {
+		t.Errorf("want: PC = c000; got PC = %04x", m.CPU.PC)
+	}
+}
+
+func TestConditionalReturn(t *testing.T) {
+	tests := []struct {
+		name  string
+		op    uint8
+		flags bool
+		taken bool
+	}{
+		{"RET Z with Z set", 0xC8, true, true},
+		{"RET Z with Z clear", 0xC8, false, false},
+		{"RET C with C set", 0xD8, true, true},
+		{"RET C with C clear", 0xD8, false, false},
+		{"RET NZ with Z set", 0xC0, true, false},
+		{"RET NC with C clear", 0xD0, false, true},
+	}
+
+	for _, tt := range tests {
+		m := newTestMachine()
+		m.CPU.PC = 0xC000
+		m.CPU.SP = 0xCFFE
+		m.CPU.SetZFlag(tt.flags)
+		m.CPU.SetCFlag(tt.flags)
+		loadProgram(m, 0xC000, tt.op)
+		loadProgram(m, 0xCFFE, 0x34, 0x12)
+
+		m.step()
+
+		want := uint16(0xC001)
+		if tt.taken {
+			want = 0x1234
+		}
+		if m.CPU.PC != want {
+			t.Errorf("%s: want: PC = %04x; got PC = %04x", tt.name, want, m.CPU.PC)
+		}
+	}
+}
+
+func TestCarryIntoADCSBC(t *testing.T) {
+	tests := []struct {
+		name  string
+		op    uint8
+		carry bool
+		want  uint8
+	}{
+		{"ADC A, C with C clear", 0x89, false, 0x10 + 0x02},
+		{"ADC A, C with C set", 0x89, true, 0x10 + 0x02 + 1},
+		{"SBC A, C with C clear", 0x99, false, 0x10 - 0x02},
+		{"SBC A, C with C set", 0x99, true, 0x10 - 0x02 - 1},
+		{"ADC A, n8 with C clear", 0xCE, false, 0x10 + 0x02},
+		{"SBC A, n8 with C set", 0xDE, true, 0x10 - 0x02 - 1},
+	}
+
+	for _, tt := range tests {
+		m := newTestMachine()
+		m.CPU.PC = 0xC000
+		m.CPU.A, m.CPU.C = 0x10, 0x02
+		m.CPU.SetCFlag(tt.carry)
+		loadProgram(m, 0xC000, tt.op, 0x02)
+
+		m.step()
+
+		if m.CPU.A != tt.want {
+			t.Errorf("%s: want: A = %02x; got A = %02x", tt.name, tt.want, m.CPU.A)
+		}
+	}
+}
+
+func TestALUOperands(t *testing.T) {
+	tests := []struct {
+		name string
+		op   uint8
+		want uint8
+	}{
+		{"ADC A, B", 0x88, 0x10 + 0x02 + 1},
+		{"ADC A, A", 0x8F, 0x10 + 0x10 + 1},
+		{"SUB A, L", 0x95, 0x10 - 0x06},
+		{"XOR A, [HL]", 0xAE, 0x10 ^ 0x55},
+	}
+
+	for _, tt := range tests {
+		m := newTestMachine()
+		m.CPU.PC = 0xC000
+		m.CPU.A, m.CPU.B = 0x10, 0x02
+		m.CPU.SetHL(0xD006)
+		m.CPU.SetCFlag(true)
+		m.Bus.Write(0xD006, 0x55)
+		loadProgram(m, 0xC000, tt.op)
+
+		m.step()
+
+		if m.CPU.A != tt.want {
+			t.Errorf("%s: want: A = %02x; got A = %02x", tt.name, tt.want, m.CPU.A)
+		}
+	}
+}
+
+func TestAddHLHL(t *testing.T) {
+	m := newTestMachine()
+	m.CPU.PC = 0xC000
+	m.CPU.SetHL(0x8800)
+	// ADD HL, HL
+	loadProgram(m, 0xC000, 0x29)
+
+	m.step()
+
+	if m.CPU.HL() != 0x1000 || !m.CPU.HFlag() || !m.CPU.CFlag() {
+		t.Errorf("want: HL = 1000 with H and C; got HL = %04x, H = %t, C = %t", m.CPU.HL(), m.CPU.HFlag(), m.CPU.CFlag())
+	}
+}
+
+func TestAddSPFlags(t *testing.T) {
+	m := newTestMachine()
+	m.CPU.PC = 0xC000
+	m.CPU.SP = 0xFFF8
+	// ADD SP, 8
+	loadProgram(m, 0xC000, 0xE8, 0x08)
+
+	m.step()
+
+	// Flags come from the low byte of SP before the addition
+	if m.CPU.SP != 0x0000 || !m.CPU.HFlag() || !m.CPU.CFlag() {
+		t.Errorf("want: SP = 0000 with H and C; got SP = %04x, H = %t, C = %t", m.CPU.SP, m.CPU.HFlag(), m.CPU.CFlag())
+	}
+}
+
+func TestInternalCycles(t *testing.T) {
+	tests := []struct {
+		name   string
+		code   []uint8
+		cycles int
+	}{
+		{"INC BC", []uint8{0x03}, 2},
+		{"ADD HL, DE", []uint8{0x19}, 2},
+		{"JR e8", []uint8{0x18, 0x00}, 3},
+		{"JR Z, e8 not taken", []uint8{0x28, 0x00}, 2},
+		{"JP a16", []uint8{0xC3, 0x00, 0xC0}, 4},
+		{"CALL a16", []uint8{0xCD, 0x00, 0xC0}, 6},
+		{"RET", []uint8{0xC9}, 4},
+		{"RET Z not taken", []uint8{0xC8}, 2},
+		{"RET NZ taken", []uint8{0xC0}, 5},
+		{"RST $38", []uint8{0xFF}, 4},
+		{"PUSH BC", []uint8{0xC5}, 4},
+		{"LD SP, HL", []uint8{0xF9}, 2},
+		{"LD HL, SP + e8", []uint8{0xF8, 0x01}, 3},
+		{"ADD SP, e8", []uint8{0xE8, 0x01}, 4},
+	}
+
+	for _, tt := range tests {
+		m := newTestMachine()
+		m.CPU.PC = 0xC000
+		m.CPU.SP = 0xD000
+		m.CPU.F = 0
+		loadProgram(m, 0xC000, tt.code...)
+		m.MCycles = 0
+
+		m.CPU.Execute(m.CPU.Fetch())
+
+		if m.MCycles != tt.cycles {
+			t.Errorf("%s: want: %d cycles; got %d cycles", tt.name, tt.cycles, m.MCycles)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package cpu
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Disassemble decodes the instruction at addr, returning its text and length
+// in bytes
+func Disassemble(read func(addr uint16) uint8, addr uint16) (string, int) {
+	in := Lookup(read(addr))
+	if in.Mnemonic == "PREFIX" {
+		in = LookupCB(read(addr + 1))
+	}
+
+	n8 := read(addr + 1)
+	n16 := uint16(read(addr+2))<<8 | uint16(n8)
+
+	operands := make([]string, len(in.Operands))
+	for i, operand := range in.Operands {
+		var value string
+		placeholder := ""
+
+		switch operand.Kind {
+		case OperandN16:
+			placeholder, value = "n16", fmt.Sprintf("$%04X", n16)
+		case OperandA16:
+			placeholder, value = "a16", fmt.Sprintf("$%04X", n16)
+		case OperandA8:
+			placeholder, value = "a8", fmt.Sprintf("$FF%02X", n8)
+		case OperandN8:
+			placeholder, value = "n8", fmt.Sprintf("$%02X", n8)
+		case OperandE8:
+			// Jumps show their target, SP offsets the signed value
+			placeholder, value = "e8", fmt.Sprintf("%d", int8(n8))
+			if in.Mnemonic == "JR" {
+				value = fmt.Sprintf("$%04X", addr+uint16(in.Length)+uint16(int8(n8)))
+			}
+		}
+
+		operands[i] = operand.Name
+		if placeholder != "" {
+			operands[i] = strings.Replace(operand.Name, placeholder, value, 1)
+		}
+	}
+
+	if len(operands) == 0 {
+		return in.Mnemonic, in.Length
+	}
+	return in.Mnemonic + " " + strings.Join(operands, ", "), in.Length
+}
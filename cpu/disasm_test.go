@@ -0,0 +1,38 @@
+package cpu
+
+import "testing"
+
+func TestDisassemble(t *testing.T) {
+	tests := []struct {
+		code   []uint8
+		text   string
+		length int
+	}{
+		{[]uint8{0x00}, "NOP", 1},
+		{[]uint8{0x01, 0x34, 0x12}, "LD BC, $1234", 3},
+		{[]uint8{0x3E, 0x42}, "LD A, $42", 2},
+		{[]uint8{0xE0, 0x40}, "LDH [$FF40], A", 2},
+		{[]uint8{0xC3, 0x50, 0x01}, "JP $0150", 3},
+		{[]uint8{0x18, 0xFE}, "JR $C000", 2},
+		{[]uint8{0x20, 0x10}, "JR NZ, $C012", 2},
+		{[]uint8{0xE8, 0xF8}, "ADD SP, -8", 2},
+		{[]uint8{0xCB, 0x37}, "SWAP A", 2},
+		{[]uint8{0xCB, 0x7E}, "BIT 7, [HL]", 2},
+		{[]uint8{0xCB, 0xC1}, "SET 0, C", 2},
+		{[]uint8{0xDD}, "ILLEGAL_DD", 1},
+	}
+
+	for _, tt := range tests {
+		read := func(addr uint16) uint8 {
+			if i := int(addr) - 0xC000; i < len(tt.code) {
+				return tt.code[i]
+			}
+			return 0
+		}
+
+		text, length := Disassemble(read, 0xC000)
+		if text != tt.text || length != tt.length {
+			t.Errorf("% X: want: %q, %d bytes; got %q, %d bytes", tt.code, tt.text, tt.length, text, length)
+		}
+	}
+}
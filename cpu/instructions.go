@@ -0,0 +1,358 @@
+package cpu
+
+// Handlers of the opcode table. They get the opcode, whose bits select
+// registers, conditions and operations the way readR8, readR16 and
+// condition decode them. Any machine cycle of an instruction which is not a
+// memory access is an explicit Tick
+
+func (c *CPU) nop(op uint8) {}
+
+func (c *CPU) stop(op uint8) {
+	c.PC++
+}
+
+func (c *CPU) halt(op uint8) {
+	if !c.IME && c.interrupts.Pending() != 0 {
+		c.HaltBug = true
+	} else {
+		c.Halt = true
+	}
+}
+
+func (c *CPU) di(op uint8) {
+	c.IME = false
+	c.IMEPending = false
+}
+
+func (c *CPU) ei(op uint8) {
+	c.IMEPending = true
+}
+
+func (c *CPU) illegal(op uint8) {
+	c.Locked = true
+}
+
+func (c *CPU) prefix(op uint8) {
+	cb := c.readPC()
+	cbOpcodes[cb].execute(c, cb)
+}
+
+// LD B, B
+func (c *CPU) breakpoint(op uint8) {
+	if c.OnBreakpoint != nil {
+		c.OnBreakpoint()
+	}
+}
+
+// LD r, r' and LD r, n8
+func (c *CPU) ldR8R8(op uint8) { c.writeR8(op>>3&0x07, c.readR8(op&0x07)) }
+func (c *CPU) ldR8N8(op uint8) { c.writeR8(op>>3&0x07, c.readPC()) }
+
+func (c *CPU) ldR16N16(op uint8) {
+	c.writeR16(op>>4&0x03, c.readPC16())
+}
+
+// LD [rr], A and LD A, [rr] go through BC, DE, HL incremented or HL
+// decremented
+func (c *CPU) indirect(op uint8) uint16 {
+	switch op >> 4 & 0x03 {
+	case 0:
+		return c.BC()
+	case 1:
+		return c.DE()
+	case 2:
+		hl := c.HL()
+		c.SetHL(hl + 1)
+		return hl
+	default:
+		hl := c.HL()
+		c.SetHL(hl - 1)
+		return hl
+	}
+}
+
+func (c *CPU) ldIndirectA(op uint8) { c.write(c.indirect(op), c.A) }
+func (c *CPU) ldAIndirect(op uint8) { c.A = c.read(c.indirect(op)) }
+
+func (c *CPU) ldA16A(op uint8) { c.write(c.readPC16(), c.A) }
+func (c *CPU) ldAA16(op uint8) { c.A = c.read(c.readPC16()) }
+
+func (c *CPU) ldhA8A(op uint8) { c.write(0xFF00+uint16(c.readPC()), c.A) }
+func (c *CPU) ldhAA8(op uint8) { c.A = c.read(0xFF00 + uint16(c.readPC())) }
+func (c *CPU) ldhCA(op uint8)  { c.write(0xFF00+uint16(c.C), c.A) }
+func (c *CPU) ldhAC(op uint8)  { c.A = c.read(0xFF00 + uint16(c.C)) }
+
+func (c *CPU) ldA16SP(op uint8) {
+	addr := c.readPC16()
+
+	c.write(addr, uint8(c.SP&0xFF))
+	c.write(addr+1, uint8(c.SP>>8))
+}
+
+func (c *CPU) ldSPHL(op uint8) {
+	c.SP = c.HL()
+	c.bus.Tick()
+}
+
+func (c *CPU) ldHLSPE8(op uint8) {
+	c.SetHL(c.addSP(int8(c.readPC())))
+	c.bus.Tick()
+}
+
+func (c *CPU) addSPE8(op uint8) {
+	c.SP = c.addSP(int8(c.readPC()))
+	c.bus.Tick()
+	c.bus.Tick()
+}
+
+func (c *CPU) incR8(op uint8) {
+	r := op >> 3 & 0x07
+	c.writeR8(r, c.inc(c.readR8(r)))
+}
+
+func (c *CPU) decR8(op uint8) {
+	r := op >> 3 & 0x07
+	c.writeR8(r, c.dec(c.readR8(r)))
+}
+
+func (c *CPU) incR16(op uint8) {
+	r := op >> 4 & 0x03
+	c.writeR16(r, c.readR16(r)+1)
+	c.bus.Tick()
+}
+
+func (c *CPU) decR16(op uint8) {
+	r := op >> 4 & 0x03
+	c.writeR16(r, c.readR16(r)-1)
+	c.bus.Tick()
+}
+
+func (c *CPU) addHLR16(op uint8) {
+	value := c.readR16(op >> 4 & 0x03)
+
+	c.SetHL(c.HL() + value)
+	c.SetNFlag(false)
+	c.SetHFlag((c.HL() & 0xFFF) < (value & 0xFFF))
+	c.SetCFlag(c.HL() < value)
+
+	c.bus.Tick()
+}
+
+// ADD, ADC, SUB, SBC, AND, XOR, OR and CP, on a register or an immediate
+func (c *CPU) aluR8(op uint8) { c.alu(op>>3&0x07, c.readR8(op&0x07)) }
+func (c *CPU) aluN8(op uint8) { c.alu(op>>3&0x07, c.readPC()) }
+
+func (c *CPU) alu(operation uint8, value uint8) {
+	switch operation {
+	case 0:
+		c.A = c.add(c.A, value, 0)
+	case 1:
+		c.A = c.add(c.A, value, c.carryBit())
+	case 2:
+		c.A = c.sub(c.A, value, 0)
+	case 3:
+		c.A = c.sub(c.A, value, c.carryBit())
+	case 4:
+		c.A = c.and(c.A, value)
+	case 5:
+		c.A = c.xor(c.A, value)
+	case 6:
+		c.A = c.or(c.A, value)
+	default:
+		c.cp(c.A, value)
+	}
+}
+
+// RLCA, RRCA, RLA and RRA always clear Z
+func (c *CPU) rotateA(op uint8) {
+	switch op >> 3 & 0x03 {
+	case 0:
+		c.A = c.rl(c.A, false)
+	case 1:
+		c.A = c.rr(c.A, false)
+	case 2:
+		c.A = c.rl(c.A, true)
+	default:
+		c.A = c.rr(c.A, true)
+	}
+	c.SetZFlag(false)
+}
+
+func (c *CPU) daa(op uint8) {
+	a := c.A
+	carry := c.CFlag()
+	h := c.HFlag()
+	n := c.NFlag()
+
+	if !n {
+		if carry || a > 0x99 {
+			a += 0x60
+			c.SetCFlag(true)
+		}
+		if h || a&0x0F > 0x09 {
+			a += 0x06
+		}
+	} else {
+		if carry {
+			a -= 0x60
+		}
+		if h {
+			a -= 0x06
+		}
+	}
+
+	c.SetZFlag(a == 0)
+	c.SetHFlag(false)
+	c.A = a
+}
+
+func (c *CPU) cpl(op uint8) {
+	c.A = 0xFF ^ c.A
+	c.SetNFlag(true)
+	c.SetHFlag(true)
+}
+
+func (c *CPU) scf(op uint8) {
+	c.SetNFlag(false)
+	c.SetHFlag(false)
+	c.SetCFlag(true)
+}
+
+func (c *CPU) ccf(op uint8) {
+	c.SetNFlag(false)
+	c.SetHFlag(false)
+	c.SetCFlag(!c.CFlag())
+}
+
+func (c *CPU) jr(op uint8) {
+	e := int8(c.readPC())
+	c.PC += uint16(e)
+	c.bus.Tick()
+}
+
+func (c *CPU) jrCond(op uint8) {
+	e := int8(c.readPC())
+	if c.condition(op) {
+		c.PC += uint16(e)
+		c.bus.Tick()
+	}
+}
+
+func (c *CPU) jp(op uint8) {
+	c.PC = c.readPC16()
+	c.bus.Tick()
+}
+
+func (c *CPU) jpCond(op uint8) {
+	addr := c.readPC16()
+	if c.condition(op) {
+		c.PC = addr
+		c.bus.Tick()
+	}
+}
+
+func (c *CPU) jpHL(op uint8) {
+	c.PC = c.HL()
+}
+
+func (c *CPU) call(op uint8) {
+	addr := c.readPC16()
+	c.bus.Tick()
+	c.push(c.PC)
+	c.PC = addr
+}
+
+func (c *CPU) callCond(op uint8) {
+	addr := c.readPC16()
+	if c.condition(op) {
+		c.bus.Tick()
+		c.push(c.PC)
+		c.PC = addr
+	}
+}
+
+func (c *CPU) ret(op uint8) {
+	c.PC = c.pop()
+	c.bus.Tick()
+}
+
+// Checking the condition takes a cycle of its own
+func (c *CPU) retCond(op uint8) {
+	c.bus.Tick()
+	if c.condition(op) {
+		c.ret(op)
+	}
+}
+
+func (c *CPU) reti(op uint8) {
+	c.ret(op)
+	c.IME = true
+	c.IMEPending = false
+}
+
+func (c *CPU) rst(op uint8) {
+	c.bus.Tick()
+	c.push(c.PC)
+	c.PC = uint16(op & 0x38)
+}
+
+// PUSH and POP go through AF where other instructions use SP
+func (c *CPU) push16(op uint8) {
+	value := c.AF()
+	if r := op >> 4 & 0x03; r != 3 {
+		value = c.readR16(r)
+	}
+
+	c.bus.Tick()
+	c.push(value)
+}
+
+func (c *CPU) pop16(op uint8) {
+	value := c.pop()
+	if r := op >> 4 & 0x03; r != 3 {
+		c.writeR16(r, value)
+	} else {
+		c.SetAF(value & 0xFFF0)
+	}
+}
+
+// CB prefixed opcodes
+func (c *CPU) shiftR8(op uint8) {
+	r := op & 0x07
+	value := c.readR8(r)
+
+	switch op >> 3 & 0x07 {
+	case 0:
+		value = c.rl(value, false)
+	case 1:
+		value = c.rr(value, false)
+	case 2:
+		value = c.rl(value, true)
+	case 3:
+		value = c.rr(value, true)
+	case 4:
+		value = c.sla(value)
+	case 5:
+		value = c.sra(value)
+	case 6:
+		value = c.swap(value)
+	default:
+		value = c.srl(value)
+	}
+
+	c.writeR8(r, value)
+}
+
+func (c *CPU) bitR8(op uint8) {
+	c.bit(op>>3&0x07, c.readR8(op&0x07))
+}
+
+func (c *CPU) resR8(op uint8) {
+	r := op & 0x07
+	c.writeR8(r, c.readR8(r)&^(1<<(op>>3&0x07)))
+}
+
+func (c *CPU) setR8(op uint8) {
+	r := op & 0x07
+	c.writeR8(r, c.readR8(r)|1<<(op>>3&0x07))
+}
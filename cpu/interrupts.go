@@ -0,0 +1,61 @@
+package cpu
+
+import (
+	"math/bits"
+
+	"github.com/hmatheisen/gameboy/interrupt"
+)
+
+// Any pending interrupt wakes the CPU from HALT, but it is only dispatched
+// when IME is set, otherwise execution resumes after the HALT. Dispatching
+// takes 5 machine cycles: 2 idle ones, 2 to push PC on the stack and 1 to
+// jump to the vector
+func (c *CPU) handleInterrupts() bool {
+	if c.interrupts.Pending() == 0 {
+		return false
+	}
+
+	halted := c.Halt
+	c.Halt = false
+
+	if !c.IME {
+		return false
+	}
+
+	// Waking up from HALT costs one more cycle before the dispatch starts
+	if halted {
+		c.bus.Tick()
+	}
+
+	c.IME = false
+	c.bus.Tick()
+	c.bus.Tick()
+
+	// An EI; HALT sequence hitting the halt bug returns to the HALT itself
+	if c.HaltBug {
+		c.HaltBug = false
+		c.PC--
+	}
+
+	c.SP -= 1
+	c.write(c.SP, uint8(c.PC>>8))
+
+	// Pushing the high byte of PC can overwrite IE, in which case the
+	// interrupt is cancelled and execution continues at 0x0000
+	pending := c.interrupts.Pending()
+
+	c.SP -= 1
+	c.write(c.SP, uint8(c.PC&0xFF))
+
+	if pending == 0 {
+		c.PC = 0x0000
+	} else {
+		interrupt := interrupt.Interrupt(1 << bits.TrailingZeros8(pending))
+		c.interrupts.Flag &^= uint8(interrupt)
+		c.PC = interrupt.Vector()
+	}
+
+	c.bus.Tick()
+
+	return true
+}
@@ -0,0 +1,120 @@
+package cpu
+
+import (
+	"testing"
+
+	"github.com/hmatheisen/gameboy/interrupt"
+)
+
+func TestInterruptDispatch(t *testing.T) {
+	m := newTestMachine()
+	m.CPU.PC = 0x1234
+	m.CPU.SP = 0xD000
+	m.CPU.IME = true
+	m.Interrupts.Enable = 0x1F
+	m.Interrupts.Flag = 0x00
+	m.Interrupts.Request(interrupt.Joypad)
+	m.Interrupts.Request(interrupt.Timer)
+
+	m.step()
+
+	if m.CPU.PC != 0x50 {
+		t.Errorf("want: PC = 0x50; got PC = %x", m.CPU.PC)
+	}
+	if m.CPU.IME {
+		t.Errorf("want: IME = false; got IME = %t", m.CPU.IME)
+	}
+	if m.Interrupts.Flag != uint8(interrupt.Joypad) {
+		t.Errorf("want: IF = %05b; got IF = %05b", interrupt.Joypad, m.Interrupts.Flag)
+	}
+	if m.CPU.SP != 0xCFFE {
+		t.Errorf("want: SP = 0xCFFE; got SP = %x", m.CPU.SP)
+	}
+	if m.Bus.Read(0xCFFF) != 0x12 || m.Bus.Read(0xCFFE) != 0x34 {
+		t.Errorf("want: stack = 12 34; got stack = %x %x", m.Bus.Read(0xCFFF), m.Bus.Read(0xCFFE))
+	}
+	if m.MCycles != 5 {
+		t.Errorf("want: 5 cycles; got %d cycles", m.MCycles)
+	}
+}
+
+func TestInterruptPriority(t *testing.T) {
+	m := newTestMachine()
+	m.CPU.SP = 0xD000
+	m.Interrupts.Enable = uint8(interrupt.Serial | interrupt.LCD)
+	m.Interrupts.Request(interrupt.VBlank)
+	m.Interrupts.Request(interrupt.LCD)
+	m.Interrupts.Request(interrupt.Serial)
+
+	for _, vector := range []uint16{0x48, 0x58} {
+		m.CPU.IME = true
+		m.step()
+
+		if m.CPU.PC != vector {
+			t.Errorf("want: PC = %x; got PC = %x", vector, m.CPU.PC)
+		}
+	}
+
+	if m.Interrupts.Flag != uint8(interrupt.VBlank) {
+		t.Errorf("want: IF = %05b; got IF = %05b", interrupt.VBlank, m.Interrupts.Flag)
+	}
+}
+
+func TestInterruptIMEDisabled(t *testing.T) {
+	m := newTestMachine()
+	m.CPU.PC = 0xC000
+	m.Interrupts.Enable = 0x1F
+	m.Interrupts.Request(interrupt.VBlank)
+	loadProgram(m, 0xC000, 0x00)
+
+	m.step()
+
+	if m.CPU.PC != 0xC001 {
+		t.Errorf("want: PC = 0xC001; got PC = %x", m.CPU.PC)
+	}
+	if m.Interrupts.Flag != uint8(interrupt.VBlank) {
+		t.Errorf("want: IF = %05b; got IF = %05b", interrupt.VBlank, m.Interrupts.Flag)
+	}
+}
+
+func TestInterruptCancelledByIEPush(t *testing.T) {
+	m := newTestMachine()
+	m.CPU.PC = 0x0200
+	m.CPU.SP = 0x0000
+	m.CPU.IME = true
+	m.Interrupts.Enable = uint8(interrupt.VBlank)
+	m.Interrupts.Request(interrupt.VBlank)
+
+	m.step()
+
+	if m.CPU.PC != 0x0000 {
+		t.Errorf("want: PC = 0x0000; got PC = %x", m.CPU.PC)
+	}
+	if m.Interrupts.Flag != uint8(interrupt.VBlank) {
+		t.Errorf("want: IF = %05b; got IF = %05b", interrupt.VBlank, m.Interrupts.Flag)
+	}
+}
+
+func TestInterruptWakesHalt(t *testing.T) {
+	m := newTestMachine()
+	m.CPU.SP = 0xD000
+	m.CPU.IME = true
+	m.CPU.Halt = true
+	m.Interrupts.Enable = uint8(interrupt.Timer)
+
+	m.step()
+
+	if !m.CPU.Halt || m.MCycles != 1 {
+		t.Errorf("want: halted for 1 cycle; got halt = %t after %d cycles", m.CPU.Halt, m.MCycles)
+	}
+
+	m.Interrupts.Request(interrupt.Timer)
+	m.step()
+
+	if m.CPU.Halt {
+		t.Errorf("want: Halt = false; got Halt = %t", m.CPU.Halt)
+	}
+	if m.CPU.PC != 0x50 {
+		t.Errorf("want: PC = 0x50; got PC = %x", m.CPU.PC)
+	}
+}
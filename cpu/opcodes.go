@@ -0,0 +1,464 @@
+package cpu
+
+import (
+	"fmt"
+	"strings"
+)
+
+// OperandKind tells what an operand stands for. The immediate kinds are
+// read from the bytes following the opcode
+type OperandKind int
+
+const (
+	OperandRegister  OperandKind = iota // A, B, ..., AF, BC, DE, HL, SP
+	OperandIndirect                     // Memory at a register: [BC], [HL+], [C]
+	OperandCondition                    // NZ, Z, NC, C
+	OperandVector                       // RST target
+	OperandBit                          // Bit number of BIT, RES and SET
+	OperandN8                           // Immediate byte
+	OperandN16                          // Immediate word
+	OperandA8                           // [a8], an address in the 0xFF00 page
+	OperandA16                          // [a16] in memory, or a16 as a jump target
+	OperandE8                           // Signed offset, from PC or SP
+)
+
+// Bytes of the operand following the opcode
+func (k OperandKind) size() int {
+	switch k {
+	case OperandN8, OperandA8, OperandE8:
+		return 1
+	case OperandN16, OperandA16:
+		return 2
+	default:
+		return 0
+	}
+}
+
+type Operand struct {
+	Kind OperandKind
+	// As written, with the immediate ones standing for their value, e.g.
+	// "[a16]" or "SP + e8"
+	Name string
+}
+
+// Instruction describes an opcode: how it is written, how long it is and
+// how it runs
+type Instruction struct {
+	Mnemonic string
+	Operands []Operand
+	Length   int // Bytes, opcodes included
+
+	// Machine cycles, fetches included. Conditional instructions take
+	// Cycles when the condition fails and BranchCycles when it holds
+	Cycles       int
+	BranchCycles int
+
+	execute func(c *CPU, op uint8)
+}
+
+func (in *Instruction) String() string {
+	names := make([]string, len(in.Operands))
+	for i, operand := range in.Operands {
+		names[i] = operand.Name
+	}
+
+	if len(names) == 0 {
+		return in.Mnemonic
+	}
+	return in.Mnemonic + " " + strings.Join(names, ", ")
+}
+
+// Lookup returns the instruction for an unprefixed opcode. 0xCB is only the
+// prefix, the instruction it starts is given by LookupCB
+func Lookup(op uint8) *Instruction {
+	return &opcodes[op]
+}
+
+// LookupCB returns the instruction for the byte following the 0xCB prefix
+func LookupCB(op uint8) *Instruction {
+	return &cbOpcodes[op]
+}
+
+type opcode struct {
+	text         string
+	cycles       int
+	branchCycles int
+	execute      func(c *CPU, op uint8)
+}
+
+// Unprefixed opcodes, where n8, n16, a8, a16 and e8 stand for the immediate
+// operands following the opcode
+var opcodeTable = [256]opcode{
+	0x00: {"NOP", 1, 0, (*CPU).nop},
+	0x01: {"LD BC, n16", 3, 0, (*CPU).ldR16N16},
+	0x02: {"LD [BC], A", 2, 0, (*CPU).ldIndirectA},
+	0x03: {"INC BC", 2, 0, (*CPU).incR16},
+	0x04: {"INC B", 1, 0, (*CPU).incR8},
+	0x05: {"DEC B", 1, 0, (*CPU).decR8},
+	0x06: {"LD B, n8", 2, 0, (*CPU).ldR8N8},
+	0x07: {"RLCA", 1, 0, (*CPU).rotateA},
+	0x08: {"LD [a16], SP", 5, 0, (*CPU).ldA16SP},
+	0x09: {"ADD HL, BC", 2, 0, (*CPU).addHLR16},
+	0x0A: {"LD A, [BC]", 2, 0, (*CPU).ldAIndirect},
+	0x0B: {"DEC BC", 2, 0, (*CPU).decR16},
+	0x0C: {"INC C", 1, 0, (*CPU).incR8},
+	0x0D: {"DEC C", 1, 0, (*CPU).decR8},
+	0x0E: {"LD C, n8", 2, 0, (*CPU).ldR8N8},
+	0x0F: {"RRCA", 1, 0, (*CPU).rotateA},
+
+	0x10: {"STOP n8", 1, 0, (*CPU).stop},
+	0x11: {"LD DE, n16", 3, 0, (*CPU).ldR16N16},
+	0x12: {"LD [DE], A", 2, 0, (*CPU).ldIndirectA},
+	0x13: {"INC DE", 2, 0, (*CPU).incR16},
+	0x14: {"INC D", 1, 0, (*CPU).incR8},
+	0x15: {"DEC D", 1, 0, (*CPU).decR8},
+	0x16: {"LD D, n8", 2, 0, (*CPU).ldR8N8},
+	0x17: {"RLA", 1, 0, (*CPU).rotateA},
+	0x18: {"JR e8", 3, 0, (*CPU).jr},
+	0x19: {"ADD HL, DE", 2, 0, (*CPU).addHLR16},
+	0x1A: {"LD A, [DE]", 2, 0, (*CPU).ldAIndirect},
+	0x1B: {"DEC DE", 2, 0, (*CPU).decR16},
+	0x1C: {"INC E", 1, 0, (*CPU).incR8},
+	0x1D: {"DEC E", 1, 0, (*CPU).decR8},
+	0x1E: {"LD E, n8", 2, 0, (*CPU).ldR8N8},
+	0x1F: {"RRA", 1, 0, (*CPU).rotateA},
+
+	0x20: {"JR NZ, e8", 2, 3, (*CPU).jrCond},
+	0x21: {"LD HL, n16", 3, 0, (*CPU).ldR16N16},
+	0x22: {"LD [HL+], A", 2, 0, (*CPU).ldIndirectA},
+	0x23: {"INC HL", 2, 0, (*CPU).incR16},
+	0x24: {"INC H", 1, 0, (*CPU).incR8},
+	0x25: {"DEC H", 1, 0, (*CPU).decR8},
+	0x26: {"LD H, n8", 2, 0, (*CPU).ldR8N8},
+	0x27: {"DAA", 1, 0, (*CPU).daa},
+	0x28: {"JR Z, e8", 2, 3, (*CPU).jrCond},
+	0x29: {"ADD HL, HL", 2, 0, (*CPU).addHLR16},
+	0x2A: {"LD A, [HL+]", 2, 0, (*CPU).ldAIndirect},
+	0x2B: {"DEC HL", 2, 0, (*CPU).decR16},
+	0x2C: {"INC L", 1, 0, (*CPU).incR8},
+	0x2D: {"DEC L", 1, 0, (*CPU).decR8},
+	0x2E: {"LD L, n8", 2, 0, (*CPU).ldR8N8},
+	0x2F: {"CPL", 1, 0, (*CPU).cpl},
+
+	0x30: {"JR NC, e8", 2, 3, (*CPU).jrCond},
+	0x31: {"LD SP, n16", 3, 0, (*CPU).ldR16N16},
+	0x32: {"LD [HL-], A", 2, 0, (*CPU).ldIndirectA},
+	0x33: {"INC SP", 2, 0, (*CPU).incR16},
+	0x34: {"INC [HL]", 3, 0, (*CPU).incR8},
+	0x35: {"DEC [HL]", 3, 0, (*CPU).decR8},
+	0x36: {"LD [HL], n8", 3, 0, (*CPU).ldR8N8},
+	0x37: {"SCF", 1, 0, (*CPU).scf},
+	0x38: {"JR C, e8", 2, 3, (*CPU).jrCond},
+	0x39: {"ADD HL, SP", 2, 0, (*CPU).addHLR16},
+	0x3A: {"LD A, [HL-]", 2, 0, (*CPU).ldAIndirect},
+	0x3B: {"DEC SP", 2, 0, (*CPU).decR16},
+	0x3C: {"INC A", 1, 0, (*CPU).incR8},
+	0x3D: {"DEC A", 1, 0, (*CPU).decR8},
+	0x3E: {"LD A, n8", 2, 0, (*CPU).ldR8N8},
+	0x3F: {"CCF", 1, 0, (*CPU).ccf},
+
+	0x40: {"LD B, B", 1, 0, (*CPU).breakpoint},
+	0x41: {"LD B, C", 1, 0, (*CPU).ldR8R8},
+	0x42: {"LD B, D", 1, 0, (*CPU).ldR8R8},
+	0x43: {"LD B, E", 1, 0, (*CPU).ldR8R8},
+	0x44: {"LD B, H", 1, 0, (*CPU).ldR8R8},
+	0x45: {"LD B, L", 1, 0, (*CPU).ldR8R8},
+	0x46: {"LD B, [HL]", 2, 0, (*CPU).ldR8R8},
+	0x47: {"LD B, A", 1, 0, (*CPU).ldR8R8},
+	0x48: {"LD C, B", 1, 0, (*CPU).ldR8R8},
+	0x49: {"LD C, C", 1, 0, (*CPU).ldR8R8},
+	0x4A: {"LD C, D", 1, 0, (*CPU).ldR8R8},
+	0x4B: {"LD C, E", 1, 0, (*CPU).ldR8R8},
+	0x4C: {"LD C, H", 1, 0, (*CPU).ldR8R8},
+	0x4D: {"LD C, L", 1, 0, (*CPU).ldR8R8},
+	0x4E: {"LD C, [HL]", 2, 0, (*CPU).ldR8R8},
+	0x4F: {"LD C, A", 1, 0, (*CPU).ldR8R8},
+
+	0x50: {"LD D, B", 1, 0, (*CPU).ldR8R8},
+	0x51: {"LD D, C", 1, 0, (*CPU).ldR8R8},
+	0x52: {"LD D, D", 1, 0, (*CPU).ldR8R8},
+	0x53: {"LD D, E", 1, 0, (*CPU).ldR8R8},
+	0x54: {"LD D, H", 1, 0, (*CPU).ldR8R8},
+	0x55: {"LD D, L", 1, 0, (*CPU).ldR8R8},
+	0x56: {"LD D, [HL]", 2, 0, (*CPU).ldR8R8},
+	0x57: {"LD D, A", 1, 0, (*CPU).ldR8R8},
+	0x58: {"LD E, B", 1, 0, (*CPU).ldR8R8},
+	0x59: {"LD E, C", 1, 0, (*CPU).ldR8R8},
+	0x5A: {"LD E, D", 1, 0, (*CPU).ldR8R8},
+	0x5B: {"LD E, E", 1, 0, (*CPU).ldR8R8},
+	0x5C: {"LD E, H", 1, 0, (*CPU).ldR8R8},
+	0x5D: {"LD E, L", 1, 0, (*CPU).ldR8R8},
+	0x5E: {"LD E, [HL]", 2, 0, (*CPU).ldR8R8},
+	0x5F: {"LD E, A", 1, 0, (*CPU).ldR8R8},
+
+	0x60: {"LD H, B", 1, 0, (*CPU).ldR8R8},
+	0x61: {"LD H, C", 1, 0, (*CPU).ldR8R8},
+	0x62: {"LD H, D", 1, 0, (*CPU).ldR8R8},
+	0x63: {"LD H, E", 1, 0, (*CPU).ldR8R8},
+	0x64: {"LD H, H", 1, 0, (*CPU).ldR8R8},
+	0x65: {"LD H, L", 1, 0, (*CPU).ldR8R8},
+	0x66: {"LD H, [HL]", 2, 0, (*CPU).ldR8R8},
+	0x67: {"LD H, A", 1, 0, (*CPU).ldR8R8},
+	0x68: {"LD L, B", 1, 0, (*CPU).ldR8R8},
+	0x69: {"LD L, C", 1, 0, (*CPU).ldR8R8},
+	0x6A: {"LD L, D", 1, 0, (*CPU).ldR8R8},
+	0x6B: {"LD L, E", 1, 0, (*CPU).ldR8R8},
+	0x6C: {"LD L, H", 1, 0, (*CPU).ldR8R8},
+	0x6D: {"LD L, L", 1, 0, (*CPU).ldR8R8},
+	0x6E: {"LD L, [HL]", 2, 0, (*CPU).ldR8R8},
+	0x6F: {"LD L, A", 1, 0, (*CPU).ldR8R8},
+
+	0x70: {"LD [HL], B", 2, 0, (*CPU).ldR8R8},
+	0x71: {"LD [HL], C", 2, 0, (*CPU).ldR8R8},
+	0x72: {"LD [HL], D", 2, 0, (*CPU).ldR8R8},
+	0x73: {"LD [HL], E", 2, 0, (*CPU).ldR8R8},
+	0x74: {"LD [HL], H", 2, 0, (*CPU).ldR8R8},
+	0x75: {"LD [HL], L", 2, 0, (*CPU).ldR8R8},
+	0x76: {"HALT", 1, 0, (*CPU).halt},
+	0x77: {"LD [HL], A", 2, 0, (*CPU).ldR8R8},
+	0x78: {"LD A, B", 1, 0, (*CPU).ldR8R8},
+	0x79: {"LD A, C", 1, 0, (*CPU).ldR8R8},
+	0x7A: {"LD A, D", 1, 0, (*CPU).ldR8R8},
+	0x7B: {"LD A, E", 1, 0, (*CPU).ldR8R8},
+	0x7C: {"LD A, H", 1, 0, (*CPU).ldR8R8},
+	0x7D: {"LD A, L", 1, 0, (*CPU).ldR8R8},
+	0x7E: {"LD A, [HL]", 2, 0, (*CPU).ldR8R8},
+	0x7F: {"LD A, A", 1, 0, (*CPU).ldR8R8},
+
+	0x80: {"ADD A, B", 1, 0, (*CPU).aluR8},
+	0x81: {"ADD A, C", 1, 0, (*CPU).aluR8},
+	0x82: {"ADD A, D", 1, 0, (*CPU).aluR8},
+	0x83: {"ADD A, E", 1, 0, (*CPU).aluR8},
+	0x84: {"ADD A, H", 1, 0, (*CPU).aluR8},
+	0x85: {"ADD A, L", 1, 0, (*CPU).aluR8},
+	0x86: {"ADD A, [HL]", 2, 0, (*CPU).aluR8},
+	0x87: {"ADD A, A", 1, 0, (*CPU).aluR8},
+	0x88: {"ADC A, B", 1, 0, (*CPU).aluR8},
+	0x89: {"ADC A, C", 1, 0, (*CPU).aluR8},
+	0x8A: {"ADC A, D", 1, 0, (*CPU).aluR8},
+	0x8B: {"ADC A, E", 1, 0, (*CPU).aluR8},
+	0x8C: {"ADC A, H", 1, 0, (*CPU).aluR8},
+	0x8D: {"ADC A, L", 1, 0, (*CPU).aluR8},
+	0x8E: {"ADC A, [HL]", 2, 0, (*CPU).aluR8},
+	0x8F: {"ADC A, A", 1, 0, (*CPU).aluR8},
+
+	0x90: {"SUB A, B", 1, 0, (*CPU).aluR8},
+	0x91: {"SUB A, C", 1, 0, (*CPU).aluR8},
+	0x92: {"SUB A, D", 1, 0, (*CPU).aluR8},
+	0x93: {"SUB A, E", 1, 0, (*CPU).aluR8},
+	0x94: {"SUB A, H", 1, 0, (*CPU).aluR8},
+	0x95: {"SUB A, L", 1, 0, (*CPU).aluR8},
+	0x96: {"SUB A, [HL]", 2, 0, (*CPU).aluR8},
+	0x97: {"SUB A, A", 1, 0, (*CPU).aluR8},
+	0x98: {"SBC A, B", 1, 0, (*CPU).aluR8},
+	0x99: {"SBC A, C", 1, 0, (*CPU).aluR8},
+	0x9A: {"SBC A, D", 1, 0, (*CPU).aluR8},
+	0x9B: {"SBC A, E", 1, 0, (*CPU).aluR8},
+	0x9C: {"SBC A, H", 1, 0, (*CPU).aluR8},
+	0x9D: {"SBC A, L", 1, 0, (*CPU).aluR8},
+	0x9E: {"SBC A, [HL]", 2, 0, (*CPU).aluR8},
+	0x9F: {"SBC A, A", 1, 0, (*CPU).aluR8},
+
+	0xA0: {"AND A, B", 1, 0, (*CPU).aluR8},
+	0xA1: {"AND A, C", 1, 0, (*CPU).aluR8},
+	0xA2: {"AND A, D", 1, 0, (*CPU).aluR8},
+	0xA3: {"AND A, E", 1, 0, (*CPU).aluR8},
+	0xA4: {"AND A, H", 1, 0, (*CPU).aluR8},
+	0xA5: {"AND A, L", 1, 0, (*CPU).aluR8},
+	0xA6: {"AND A, [HL]", 2, 0, (*CPU).aluR8},
+	0xA7: {"AND A, A", 1, 0, (*CPU).aluR8},
+	0xA8: {"XOR A, B", 1, 0, (*CPU).aluR8},
+	0xA9: {"XOR A, C", 1, 0, (*CPU).aluR8},
+	0xAA: {"XOR A, D", 1, 0, (*CPU).aluR8},
+	0xAB: {"XOR A, E", 1, 0, (*CPU).aluR8},
+	0xAC: {"XOR A, H", 1, 0, (*CPU).aluR8},
+	0xAD: {"XOR A, L", 1, 0, (*CPU).aluR8},
+	0xAE: {"XOR A, [HL]", 2, 0, (*CPU).aluR8},
+	0xAF: {"XOR A, A", 1, 0, (*CPU).aluR8},
+
+	0xB0: {"OR A, B", 1, 0, (*CPU).aluR8},
+	0xB1: {"OR A, C", 1, 0, (*CPU).aluR8},
+	0xB2: {"OR A, D", 1, 0, (*CPU).aluR8},
+	0xB3: {"OR A, E", 1, 0, (*CPU).aluR8},
+	0xB4: {"OR A, H", 1, 0, (*CPU).aluR8},
+	0xB5: {"OR A, L", 1, 0, (*CPU).aluR8},
+	0xB6: {"OR A, [HL]", 2, 0, (*CPU).aluR8},
+	0xB7: {"OR A, A", 1, 0, (*CPU).aluR8},
+	0xB8: {"CP A, B", 1, 0, (*CPU).aluR8},
+	0xB9: {"CP A, C", 1, 0, (*CPU).aluR8},
+	0xBA: {"CP A, D", 1, 0, (*CPU).aluR8},
+	0xBB: {"CP A, E", 1, 0, (*CPU).aluR8},
+	0xBC: {"CP A, H", 1, 0, (*CPU).aluR8},
+	0xBD: {"CP A, L", 1, 0, (*CPU).aluR8},
+	0xBE: {"CP A, [HL]", 2, 0, (*CPU).aluR8},
+	0xBF: {"CP A, A", 1, 0, (*CPU).aluR8},
+
+	0xC0: {"RET NZ", 2, 5, (*CPU).retCond},
+	0xC1: {"POP BC", 3, 0, (*CPU).pop16},
+	0xC2: {"JP NZ, a16", 3, 4, (*CPU).jpCond},
+	0xC3: {"JP a16", 4, 0, (*CPU).jp},
+	0xC4: {"CALL NZ, a16", 3, 6, (*CPU).callCond},
+	0xC5: {"PUSH BC", 4, 0, (*CPU).push16},
+	0xC6: {"ADD A, n8", 2, 0, (*CPU).aluN8},
+	0xC7: {"RST $00", 4, 0, (*CPU).rst},
+	0xC8: {"RET Z", 2, 5, (*CPU).retCond},
+	0xC9: {"RET", 4, 0, (*CPU).ret},
+	0xCA: {"JP Z, a16", 3, 4, (*CPU).jpCond},
+	0xCB: {"PREFIX", 1, 0, (*CPU).prefix},
+	0xCC: {"CALL Z, a16", 3, 6, (*CPU).callCond},
+	0xCD: {"CALL a16", 6, 0, (*CPU).call},
+	0xCE: {"ADC A, n8", 2, 0, (*CPU).aluN8},
+	0xCF: {"RST $08", 4, 0, (*CPU).rst},
+
+	0xD0: {"RET NC", 2, 5, (*CPU).retCond},
+	0xD1: {"POP DE", 3, 0, (*CPU).pop16},
+	0xD2: {"JP NC, a16", 3, 4, (*CPU).jpCond},
+	0xD3: {"ILLEGAL_D3", 1, 0, (*CPU).illegal},
+	0xD4: {"CALL NC, a16", 3, 6, (*CPU).callCond},
+	0xD5: {"PUSH DE", 4, 0, (*CPU).push16},
+	0xD6: {"SUB A, n8", 2, 0, (*CPU).aluN8},
+	0xD7: {"RST $10", 4, 0, (*CPU).rst},
+	0xD8: {"RET C", 2, 5, (*CPU).retCond},
+	0xD9: {"RETI", 4, 0, (*CPU).reti},
+	0xDA: {"JP C, a16", 3, 4, (*CPU).jpCond},
+	0xDB: {"ILLEGAL_DB", 1, 0, (*CPU).illegal},
+	0xDC: {"CALL C, a16", 3, 6, (*CPU).callCond},
+	0xDD: {"ILLEGAL_DD", 1, 0, (*CPU).illegal},
+	0xDE: {"SBC A, n8", 2, 0, (*CPU).aluN8},
+	0xDF: {"RST $18", 4, 0, (*CPU).rst},
+
+	0xE0: {"LDH [a8], A", 3, 0, (*CPU).ldhA8A},
+	0xE1: {"POP HL", 3, 0, (*CPU).pop16},
+	0xE2: {"LDH [C], A", 2, 0, (*CPU).ldhCA},
+	0xE3: {"ILLEGAL_E3", 1, 0, (*CPU).illegal},
+	0xE4: {"ILLEGAL_E4", 1, 0, (*CPU).illegal},
+	0xE5: {"PUSH HL", 4, 0, (*CPU).push16},
+	0xE6: {"AND A, n8", 2, 0, (*CPU).aluN8},
+	0xE7: {"RST $20", 4, 0, (*CPU).rst},
+	0xE8: {"ADD SP, e8", 4, 0, (*CPU).addSPE8},
+	0xE9: {"JP HL", 1, 0, (*CPU).jpHL},
+	0xEA: {"LD [a16], A", 4, 0, (*CPU).ldA16A},
+	0xEB: {"ILLEGAL_EB", 1, 0, (*CPU).illegal},
+	0xEC: {"ILLEGAL_EC", 1, 0, (*CPU).illegal},
+	0xED: {"ILLEGAL_ED", 1, 0, (*CPU).illegal},
+	0xEE: {"XOR A, n8", 2, 0, (*CPU).aluN8},
+	0xEF: {"RST $28", 4, 0, (*CPU).rst},
+
+	0xF0: {"LDH A, [a8]", 3, 0, (*CPU).ldhAA8},
+	0xF1: {"POP AF", 3, 0, (*CPU).pop16},
+	0xF2: {"LDH A, [C]", 2, 0, (*CPU).ldhAC},
+	0xF3: {"DI", 1, 0, (*CPU).di},
+	0xF4: {"ILLEGAL_F4", 1, 0, (*CPU).illegal},
+	0xF5: {"PUSH AF", 4, 0, (*CPU).push16},
+	0xF6: {"OR A, n8", 2, 0, (*CPU).aluN8},
+	0xF7: {"RST $30", 4, 0, (*CPU).rst},
+	0xF8: {"LD HL, SP + e8", 3, 0, (*CPU).ldHLSPE8},
+	0xF9: {"LD SP, HL", 2, 0, (*CPU).ldSPHL},
+	0xFA: {"LD A, [a16]", 4, 0, (*CPU).ldAA16},
+	0xFB: {"EI", 1, 0, (*CPU).ei},
+	0xFC: {"ILLEGAL_FC", 1, 0, (*CPU).illegal},
+	0xFD: {"ILLEGAL_FD", 1, 0, (*CPU).illegal},
+	0xFE: {"CP A, n8", 2, 0, (*CPU).aluN8},
+	0xFF: {"RST $38", 4, 0, (*CPU).rst},
+}
+
+var (
+	cbOperations = [8]string{"RLC", "RRC", "RL", "RR", "SLA", "SRA", "SWAP", "SRL"}
+	cbGroups     = [4]string{"", "BIT", "RES", "SET"}
+	r8Names      = [8]string{"B", "C", "D", "E", "H", "L", "[HL]", "A"}
+)
+
+var opcodes, cbOpcodes [256]Instruction
+
+func init() {
+	for op, o := range opcodeTable {
+		opcodes[op] = newInstruction(o.text, 1, o.cycles, o.branchCycles, o.execute)
+	}
+
+	// CB prefixed opcodes are laid out as 2 bits of operation group, 3 bits
+	// of operation or bit number and 3 bits of register. Going through
+	// [HL] takes a read and a write, BIT only reads it
+	for op := range cbOpcodes {
+		group := op >> 6
+		n := op >> 3 & 0x07
+		r := r8Names[op&0x07]
+
+		cycles := 2
+		if op&0x07 == 6 {
+			cycles = 4
+			if group == 1 {
+				cycles = 3
+			}
+		}
+
+		text := cbOperations[n] + " " + r
+		execute := (*CPU).shiftR8
+		switch group {
+		case 1:
+			execute = (*CPU).bitR8
+		case 2:
+			execute = (*CPU).resR8
+		case 3:
+			execute = (*CPU).setR8
+		}
+		if group != 0 {
+			text = fmt.Sprintf("%s %d, %s", cbGroups[group], n, r)
+		}
+
+		cbOpcodes[op] = newInstruction(text, 2, cycles, 0, execute)
+	}
+}
+
+// Splits the text of an instruction into its mnemonic and operands, the
+// operand kinds giving its length
+func newInstruction(text string, opcodeLength, cycles, branchCycles int, execute func(c *CPU, op uint8)) Instruction {
+	in := Instruction{Length: opcodeLength, Cycles: cycles, BranchCycles: branchCycles, execute: execute}
+
+	mnemonic, operands, _ := strings.Cut(text, " ")
+	in.Mnemonic = mnemonic
+	if operands == "" {
+		return in
+	}
+
+	for _, name := range strings.Split(operands, ", ") {
+		kind := operandKind(mnemonic, name)
+		in.Operands = append(in.Operands, Operand{Kind: kind, Name: name})
+		in.Length += kind.size()
+	}
+
+	return in
+}
+
+func operandKind(mnemonic, name string) OperandKind {
+	switch {
+	case strings.Contains(name, "n16"):
+		return OperandN16
+	case strings.Contains(name, "a16"):
+		return OperandA16
+	case strings.Contains(name, "a8"):
+		return OperandA8
+	case strings.Contains(name, "n8"):
+		return OperandN8
+	case strings.Contains(name, "e8"):
+		return OperandE8
+	case strings.HasPrefix(name, "["):
+		return OperandIndirect
+	case strings.HasPrefix(name, "$"):
+		return OperandVector
+	case name >= "0" && name <= "7":
+		return OperandBit
+	}
+
+	switch name {
+	case "NZ", "Z", "NC":
+		return OperandCondition
+	case "C":
+		// Branches have no register operand, C is the carry condition
+		switch mnemonic {
+		case "JR", "JP", "CALL", "RET":
+			return OperandCondition
+		}
+	}
+
+	return OperandRegister
+}
@@ -0,0 +1,148 @@
+package cpu
+
+import "testing"
+
+// Runs a single instruction at 0xC000 with every flag set or clear,
+// returning whether its condition held
+func runInstruction(code []uint8, flags bool) (*testMachine, bool) {
+	m := newTestMachine()
+	m.CPU.PC = 0xC000
+	m.CPU.SP = 0xD000
+	m.CPU.SetHL(0xD100)
+	m.CPU.SetZFlag(flags)
+	m.CPU.SetNFlag(flags)
+	m.CPU.SetHFlag(flags)
+	m.CPU.SetCFlag(flags)
+	loadProgram(m, 0xC000, code...)
+	taken := m.CPU.condition(code[0])
+	m.MCycles = 0
+
+	m.CPU.Execute(m.CPU.Fetch())
+
+	return m, taken
+}
+
+func TestOpcodeCycles(t *testing.T) {
+	for op := 0; op < 0x100; op++ {
+		in := Lookup(uint8(op))
+		if in.Mnemonic == "PREFIX" {
+			continue
+		}
+
+		// Flags all clear then all set, so that every condition goes both ways
+		for _, flags := range []bool{false, true} {
+			m, taken := runInstruction([]uint8{uint8(op), 0x00, 0xD0}, flags)
+
+			want := in.Cycles
+			if in.BranchCycles != 0 && taken {
+				want = in.BranchCycles
+			}
+			if m.MCycles != want {
+				t.Errorf("%02X %s, flags %t: want: %d cycles; got %d cycles", op, in, flags, want, m.MCycles)
+			}
+		}
+	}
+}
+
+func TestCBOpcodeCycles(t *testing.T) {
+	for op := 0; op < 0x100; op++ {
+		in := LookupCB(uint8(op))
+
+		m, _ := runInstruction([]uint8{0xCB, uint8(op)}, false)
+		if m.MCycles != in.Cycles {
+			t.Errorf("CB %02X %s: want: %d cycles; got %d cycles", op, in, in.Cycles, m.MCycles)
+		}
+		if m.CPU.PC != 0xC000+uint16(in.Length) {
+			t.Errorf("CB %02X %s: want: PC = %04x; got PC = %04x", op, in, 0xC000+in.Length, m.CPU.PC)
+		}
+	}
+}
+
+func TestOpcodeLength(t *testing.T) {
+	for op := 0; op < 0x100; op++ {
+		in := Lookup(uint8(op))
+		switch in.Mnemonic {
+		case "JR", "JP", "CALL", "RET", "RETI", "RST", "PREFIX":
+			// Unless a condition fails, these go elsewhere
+			if in.BranchCycles == 0 {
+				continue
+			}
+		}
+
+		for _, flags := range []bool{false, true} {
+			m, taken := runInstruction([]uint8{uint8(op), 0x00, 0xD0}, flags)
+			if in.BranchCycles != 0 && taken {
+				continue
+			}
+
+			if m.CPU.PC != 0xC000+uint16(in.Length) {
+				t.Errorf("%02X %s, flags %t: want: PC = %04x; got PC = %04x", op, in, flags, 0xC000+in.Length, m.CPU.PC)
+			}
+		}
+	}
+}
+
+func TestOpcodeOperands(t *testing.T) {
+	tests := []struct {
+		in       *Instruction
+		text     string
+		kinds    []OperandKind
+		length   int
+		cycles   int
+		branches int
+	}{
+		{Lookup(0x00), "NOP", nil, 1, 1, 0},
+		{Lookup(0x08), "LD [a16], SP", []OperandKind{OperandA16, OperandRegister}, 3, 5, 0},
+		{Lookup(0x22), "LD [HL+], A", []OperandKind{OperandIndirect, OperandRegister}, 1, 2, 0},
+		{Lookup(0x38), "JR C, e8", []OperandKind{OperandCondition, OperandE8}, 2, 2, 3},
+		{Lookup(0xC4), "CALL NZ, a16", []OperandKind{OperandCondition, OperandA16}, 3, 3, 6},
+		{Lookup(0xD8), "RET C", []OperandKind{OperandCondition}, 1, 2, 5},
+		{Lookup(0xDF), "RST $18", []OperandKind{OperandVector}, 1, 4, 0},
+		{Lookup(0xE0), "LDH [a8], A", []OperandKind{OperandA8, OperandRegister}, 2, 3, 0},
+		{Lookup(0xE2), "LDH [C], A", []OperandKind{OperandIndirect, OperandRegister}, 1, 2, 0},
+		{Lookup(0xF8), "LD HL, SP + e8", []OperandKind{OperandRegister, OperandE8}, 2, 3, 0},
+		{Lookup(0xFE), "CP A, n8", []OperandKind{OperandRegister, OperandN8}, 2, 2, 0},
+		{LookupCB(0x11), "RL C", []OperandKind{OperandRegister}, 2, 2, 0},
+		{LookupCB(0x7E), "BIT 7, [HL]", []OperandKind{OperandBit, OperandIndirect}, 2, 3, 0},
+		{LookupCB(0xC6), "SET 0, [HL]", []OperandKind{OperandBit, OperandIndirect}, 2, 4, 0},
+	}
+
+	for _, tt := range tests {
+		in := tt.in
+		if in.String() != tt.text {
+			t.Errorf("want: %q; got %q", tt.text, in.String())
+			continue
+		}
+		if in.Length != tt.length || in.Cycles != tt.cycles || in.BranchCycles != tt.branches {
+			t.Errorf("%s: want: %d bytes, %d/%d cycles; got %d bytes, %d/%d cycles", tt.text, tt.length, tt.cycles, tt.branches, in.Length, in.Cycles, in.BranchCycles)
+		}
+		if len(in.Operands) != len(tt.kinds) {
+			t.Errorf("%s: want: %d operands; got %d", tt.text, len(tt.kinds), len(in.Operands))
+			continue
+		}
+		for i, operand := range in.Operands {
+			if operand.Kind != tt.kinds[i] {
+				t.Errorf("%s: operand %d: want: kind %d; got kind %d", tt.text, i, tt.kinds[i], operand.Kind)
+			}
+		}
+	}
+}
+
+func TestBreakpoint(t *testing.T) {
+	m := newTestMachine()
+	m.CPU.PC = 0xC000
+	// LD B, B; LD C, C; LD B, B
+	loadProgram(m, 0xC000, 0x40, 0x49, 0x40)
+
+	var hits []uint16
+	m.CPU.OnBreakpoint = func() {
+		hits = append(hits, m.CPU.PC)
+	}
+	for i := 0; i < 3; i++ {
+		m.step()
+	}
+
+	if len(hits) != 2 || hits[0] != 0xC001 || hits[1] != 0xC003 {
+		t.Errorf("want: breakpoints with PC = c001, c003; got %04x", hits)
+	}
+}
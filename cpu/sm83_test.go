@@ -0,0 +1,262 @@
+package cpu
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hmatheisen/gameboy/interrupt"
+)
+
+// The SM83 single step tests (github.com/SingleStepTests/sm83) give, for
+// every opcode, random initial states along with the registers, memory and
+// bus activity expected once the instruction ran. The JSON files of their v1
+// directory go in testdata/sm83
+
+type sm83State struct {
+	A, B, C, D, E, F, H, L uint8
+	PC, SP                 uint16
+	IME                    uint8
+	IE                     uint8
+	RAM                    [][2]int
+}
+
+// A machine cycle, either a memory access or an internal one
+type sm83Cycle struct {
+	Internal bool
+	Addr     uint16
+	Value    uint8
+	Write    bool
+}
+
+// Cycles are [addr, value, activity] where the activity reads like "r-m" or
+// "-wm", internal ones are null
+func (c *sm83Cycle) UnmarshalJSON(data []byte) error {
+	var raw []any
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	if len(raw) < 3 || raw[0] == nil {
+		c.Internal = true
+		return nil
+	}
+
+	addr, okAddr := raw[0].(float64)
+	value, okValue := raw[1].(float64)
+	activity, okActivity := raw[2].(string)
+	if !okAddr || !okValue || !okActivity {
+		return fmt.Errorf("malformed cycle %s", data)
+	}
+
+	c.Addr = uint16(addr)
+	c.Value = uint8(value)
+	c.Write = strings.Contains(activity, "w")
+	c.Internal = !c.Write && !strings.Contains(activity, "r")
+	return nil
+}
+
+func (c sm83Cycle) String() string {
+	switch {
+	case c.Internal:
+		return "internal"
+	case c.Write:
+		return fmt.Sprintf("write %02x to %04x", c.Value, c.Addr)
+	default:
+		return fmt.Sprintf("read %02x from %04x", c.Value, c.Addr)
+	}
+}
+
+type sm83Case struct {
+	Name    string
+	Initial sm83State
+	Final   sm83State
+	Cycles  []sm83Cycle
+}
+
+// sm83Bus is flat memory recording every machine cycle
+type sm83Bus struct {
+	memory map[uint16]uint8
+	cycles []sm83Cycle
+}
+
+func (b *sm83Bus) Read(addr uint16) uint8 {
+	value := b.memory[addr]
+	b.cycles = append(b.cycles, sm83Cycle{Addr: addr, Value: value})
+	return value
+}
+
+func (b *sm83Bus) Write(addr uint16, value uint8) {
+	b.memory[addr] = value
+	b.cycles = append(b.cycles, sm83Cycle{Addr: addr, Value: value, Write: true})
+}
+
+func (b *sm83Bus) Tick() {
+	b.cycles = append(b.cycles, sm83Cycle{Internal: true})
+}
+
+// Executes the instruction of a test case, returning how the outcome differs
+// from the expected one
+func runSM83Case(tc sm83Case) []string {
+	b := &sm83Bus{memory: make(map[uint16]uint8)}
+	for _, ram := range tc.Initial.RAM {
+		b.memory[uint16(ram[0])] = uint8(ram[1])
+	}
+
+	interrupts := interrupt.New()
+	interrupts.Enable = tc.Initial.IE
+	c := New(b, interrupts)
+
+	in := tc.Initial
+	c.A, c.F, c.B, c.C, c.D, c.E, c.H, c.L = in.A, in.F, in.B, in.C, in.D, in.E, in.H, in.L
+	c.PC, c.SP = in.PC, in.SP
+	c.IME = in.IME != 0
+
+	c.Execute(c.Fetch())
+
+	var mismatches []string
+	want := tc.Final
+	registers := []struct {
+		name      string
+		got, want int
+	}{
+		{"A", int(c.A), int(want.A)},
+		{"F", int(c.F), int(want.F)},
+		{"B", int(c.B), int(want.B)},
+		{"C", int(c.C), int(want.C)},
+		{"D", int(c.D), int(want.D)},
+		{"E", int(c.E), int(want.E)},
+		{"H", int(c.H), int(want.H)},
+		{"L", int(c.L), int(want.L)},
+		{"PC", int(c.PC), int(want.PC)},
+		{"SP", int(c.SP), int(want.SP)},
+	}
+	for _, r := range registers {
+		if r.got != r.want {
+			mismatches = append(mismatches, fmt.Sprintf("want: %s = %02x; got %02x", r.name, r.want, r.got))
+		}
+	}
+
+	// EI only sets IME once the next instruction is done, which the tests
+	// show as set right away
+	if ime := c.IME || c.IMEPending; ime != (want.IME != 0) {
+		mismatches = append(mismatches, fmt.Sprintf("want: IME = %d; got %t", want.IME, ime))
+	}
+
+	for _, ram := range want.RAM {
+		addr, value := uint16(ram[0]), uint8(ram[1])
+		if got := b.memory[addr]; got != value {
+			mismatches = append(mismatches, fmt.Sprintf("want: [%04x] = %02x; got %02x", addr, value, got))
+		}
+	}
+
+	if len(b.cycles) != len(tc.Cycles) {
+		mismatches = append(mismatches, fmt.Sprintf("want: %d cycles; got %d cycles", len(tc.Cycles), len(b.cycles)))
+	} else {
+		for i, cycle := range tc.Cycles {
+			if got := b.cycles[i]; got != cycle {
+				mismatches = append(mismatches, fmt.Sprintf("cycle %d: want: %s; got %s", i, cycle, got))
+			}
+		}
+	}
+
+	return mismatches
+}
+
+// STOP enters a low power mode and switches speed on the CGB, none of
+// which the CPU handles on its own
+var sm83Skipped = map[string]string{
+	"10": "STOP is not emulated",
+}
+
+func TestSM83(t *testing.T) {
+	files, _ := filepath.Glob(filepath.Join("testdata", "sm83", "*.json"))
+	if len(files) == 0 {
+		t.Skip("SM83 single step tests not found in testdata")
+	}
+
+	for _, file := range files {
+		name := strings.TrimSuffix(filepath.Base(file), ".json")
+
+		t.Run(name, func(t *testing.T) {
+			if reason, ok := sm83Skipped[name]; ok {
+				t.Skip(reason)
+			}
+
+			data, err := os.ReadFile(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var cases []sm83Case
+			if err := json.Unmarshal(data, &cases); err != nil {
+				t.Fatal(err)
+			}
+
+			// A broken opcode fails most of its cases, a few tell enough
+			failures := 0
+			for _, tc := range cases {
+				mismatches := runSM83Case(tc)
+				if len(mismatches) == 0 {
+					continue
+				}
+
+				t.Errorf("%s: %s", tc.Name, strings.Join(mismatches, "; "))
+				if failures++; failures == 5 {
+					t.Fatalf("giving up after %d failures", failures)
+				}
+			}
+		})
+	}
+}
+
+// Cases in the format of the test files, to check the harness on its own
+const sm83Sample = `[
+	{
+		"name": "c5 0000",
+		"initial": {"a": 1, "b": 18, "c": 52, "d": 0, "e": 0, "f": 176, "h": 0, "l": 0, "pc": 49152, "sp": 53248, "ime": 0, "ie": 0,
+			"ram": [[49152, 197]]},
+		"final": {"a": 1, "b": 18, "c": 52, "d": 0, "e": 0, "f": 176, "h": 0, "l": 0, "pc": 49153, "sp": 53246, "ime": 0, "ie": 0,
+			"ram": [[49152, 197], [53247, 18], [53246, 52]]},
+		"cycles": [[49152, 197, "r-m"], null, [53247, 18, "-wm"], [53246, 52, "-wm"]]
+	},
+	{
+		"name": "f1 0000",
+		"initial": {"a": 0, "b": 0, "c": 0, "d": 0, "e": 0, "f": 0, "h": 0, "l": 0, "pc": 49152, "sp": 53246, "ime": 0, "ie": 0,
+			"ram": [[49152, 241], [53246, 255], [53247, 18]]},
+		"final": {"a": 18, "b": 0, "c": 0, "d": 0, "e": 0, "f": 240, "h": 0, "l": 0, "pc": 49153, "sp": 53248, "ime": 0, "ie": 0,
+			"ram": [[49152, 241], [53246, 255], [53247, 18]]},
+		"cycles": [[49152, 241, "r-m"], [53246, 255, "r-m"], [53247, 18, "r-m"]]
+	},
+	{
+		"name": "fb 0000",
+		"initial": {"a": 0, "b": 0, "c": 0, "d": 0, "e": 0, "f": 0, "h": 0, "l": 0, "pc": 49152, "sp": 53248, "ime": 0, "ie": 0,
+			"ram": [[49152, 251]]},
+		"final": {"a": 0, "b": 0, "c": 0, "d": 0, "e": 0, "f": 0, "h": 0, "l": 0, "pc": 49153, "sp": 53248, "ime": 1, "ie": 0,
+			"ram": [[49152, 251]]},
+		"cycles": [[49152, 251, "r-m"]]
+	}
+]`
+
+func TestSM83Harness(t *testing.T) {
+	var cases []sm83Case
+	if err := json.Unmarshal([]byte(sm83Sample), &cases); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range cases {
+		if mismatches := runSM83Case(tc); len(mismatches) != 0 {
+			t.Errorf("%s: %s", tc.Name, strings.Join(mismatches, "; "))
+		}
+	}
+
+	// Every kind of difference gets reported
+	tc := cases[0]
+	tc.Final.SP = 0xD000
+	tc.Final.RAM = [][2]int{{0xCFFF, 0x99}}
+	tc.Cycles = tc.Cycles[:3]
+	if mismatches := runSM83Case(tc); len(mismatches) != 3 {
+		t.Errorf("want: SP, memory and cycle count mismatches; got %q", mismatches)
+	}
+}
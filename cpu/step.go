@@ -0,0 +1,50 @@
+package cpu
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrIllegalOpcode is returned once the CPU hits one of the opcodes which
+// lock it up until reset
+var ErrIllegalOpcode = errors.New("illegal opcode")
+
+// Step runs a single instruction, services an interrupt, or idles for a
+// cycle while halted
+func (c *CPU) Step() error {
+	if c.Locked {
+		c.bus.Tick()
+		return c.lockup
+	}
+
+	if c.handleInterrupts() {
+		return nil
+	}
+
+	if c.Halt {
+		c.bus.Tick()
+		return nil
+	}
+
+	enableIME := c.IMEPending
+
+	pc := c.PC
+	if c.Trace != nil {
+		c.Trace(pc)
+	}
+	opCode := c.Fetch()
+	c.Execute(opCode)
+
+	if c.Locked {
+		c.lockup = fmt.Errorf("%w %02x at %04x", ErrIllegalOpcode, uint8(opCode), pc)
+		return c.lockup
+	}
+
+	// A pending EI lands once the next instruction is done, unless that
+	// instruction was DI
+	if enableIME && c.IMEPending {
+		c.IME = true
+		c.IMEPending = false
+	}
+	return nil
+}
@@ -0,0 +1,330 @@
+package cpu
+
+import (
+	"testing"
+
+	"github.com/hmatheisen/gameboy/interrupt"
+)
+
+// Runs the CPU until PC reaches the given address or the cycle budget is spent
+func runUntil(m *testMachine, pc uint16, maxCycles int) bool {
+	for m.MCycles < maxCycles {
+		if m.CPU.PC == pc && !m.CPU.Halt {
+			return true
+		}
+		m.step()
+	}
+
+	return false
+}
+
+func TestHaltIdlesUntilInterrupt(t *testing.T) {
+	m := newTestMachine()
+	m.CPU.PC = 0xC000
+	m.Interrupts.Enable = uint8(interrupt.Timer)
+	m.Interrupts.Flag = 0x00
+	// HALT; INC A
+	loadProgram(m, 0xC000, 0x76, 0x3C)
+	m.CPU.A = 0
+
+	m.step()
+	for i := 0; i < 10; i++ {
+		m.step()
+	}
+
+	if !m.CPU.Halt {
+		t.Fatalf("want: Halt = true; got Halt = %t", m.CPU.Halt)
+	}
+	if m.CPU.PC != 0xC001 {
+		t.Errorf("want: PC = 0xC001; got PC = %x", m.CPU.PC)
+	}
+	if m.MCycles != 11 {
+		t.Errorf("want: 11 cycles; got %d cycles", m.MCycles)
+	}
+	if m.CPU.A != 0 {
+		t.Errorf("want: A = 0; got A = %d", m.CPU.A)
+	}
+}
+
+// Modelled on mooneye halt_ime0_nointr_timing: with IME off, HALT resumes
+// after the instruction without dispatching and leaves IF untouched.
+func TestHaltIME0ResumesWithoutDispatch(t *testing.T) {
+	m := newTestMachine()
+	m.CPU.PC = 0xC000
+	m.CPU.SP = 0xD000
+	m.Interrupts.Enable = uint8(interrupt.Timer)
+	m.Interrupts.Flag = 0x00
+	// HALT; INC A; NOP
+	loadProgram(m, 0xC000, 0x76, 0x3C, 0x00)
+	m.CPU.A = 0
+
+	m.step()
+	m.step()
+	m.Interrupts.Request(interrupt.Timer)
+	m.step()
+
+	if m.CPU.Halt {
+		t.Errorf("want: Halt = false; got Halt = %t", m.CPU.Halt)
+	}
+	if m.CPU.A != 1 {
+		t.Errorf("want: A = 1; got A = %d", m.CPU.A)
+	}
+	if m.CPU.PC != 0xC002 {
+		t.Errorf("want: PC = 0xC002; got PC = %x", m.CPU.PC)
+	}
+	if m.Interrupts.Flag != uint8(interrupt.Timer) {
+		t.Errorf("want: IF = %05b; got IF = %05b", interrupt.Timer, m.Interrupts.Flag)
+	}
+	if m.CPU.SP != 0xD000 {
+		t.Errorf("want: SP = 0xD000; got SP = %x", m.CPU.SP)
+	}
+}
+
+// Modelled on mooneye halt_ime1_timing: with IME on, the interrupt is
+// serviced and returns to the instruction after HALT.
+func TestHaltIME1Dispatches(t *testing.T) {
+	m := newTestMachine()
+	m.CPU.PC = 0xC000
+	m.CPU.SP = 0xD000
+	m.CPU.IME = true
+	m.Interrupts.Enable = uint8(interrupt.Timer)
+	m.Interrupts.Flag = 0x00
+	// HALT; INC A
+	loadProgram(m, 0xC000, 0x76, 0x3C)
+	m.CPU.A = 0
+
+	m.step()
+	m.Interrupts.Request(interrupt.Timer)
+	start := m.MCycles
+	m.step()
+
+	if m.CPU.PC != 0x50 {
+		t.Errorf("want: PC = 0x50; got PC = %x", m.CPU.PC)
+	}
+	if m.MCycles-start != 6 {
+		t.Errorf("want: 6 cycles; got %d cycles", m.MCycles-start)
+	}
+	if m.Bus.Read(0xCFFF) != 0xC0 || m.Bus.Read(0xCFFE) != 0x01 {
+		t.Errorf("want: return address = c001; got %02x%02x", m.Bus.Read(0xCFFF), m.Bus.Read(0xCFFE))
+	}
+	if m.CPU.A != 0 {
+		t.Errorf("want: A = 0; got A = %d", m.CPU.A)
+	}
+}
+
+// Modelled on mooneye halt_ime0_ei: HALT with IME off and an interrupt
+// already pending does not halt, and the next byte is executed twice.
+func TestHaltBug(t *testing.T) {
+	m := newTestMachine()
+	m.CPU.PC = 0xC000
+	m.Interrupts.Enable = uint8(interrupt.Timer)
+	m.Interrupts.Flag = uint8(interrupt.Timer)
+	// HALT; INC A; NOP
+	loadProgram(m, 0xC000, 0x76, 0x3C, 0x00)
+	m.CPU.A = 0
+
+	if !runUntil(m, 0xC002, 100) {
+		t.Fatalf("want: PC = 0xC002; got PC = %x", m.CPU.PC)
+	}
+
+	if m.CPU.Halt {
+		t.Errorf("want: Halt = false; got Halt = %t", m.CPU.Halt)
+	}
+	if m.CPU.A != 2 {
+		t.Errorf("want: A = 2; got A = %d", m.CPU.A)
+	}
+}
+
+// The halt bug applies to operand bytes too: LD B, n8 reads its own opcode
+// as the immediate and the real immediate is then executed as an opcode.
+func TestHaltBugOperand(t *testing.T) {
+	m := newTestMachine()
+	m.CPU.PC = 0xC000
+	m.Interrupts.Enable = uint8(interrupt.Timer)
+	m.Interrupts.Flag = uint8(interrupt.Timer)
+	// HALT; LD B, 0x04 (0x04 is INC B)
+	loadProgram(m, 0xC000, 0x76, 0x06, 0x04)
+
+	m.step()
+	m.step()
+	m.step()
+
+	if m.CPU.B != 0x07 {
+		t.Errorf("want: B = 0x07; got B = %x", m.CPU.B)
+	}
+	if m.CPU.PC != 0xC003 {
+		t.Errorf("want: PC = 0xC003; got PC = %x", m.CPU.PC)
+	}
+}
+
+func TestEIDelay(t *testing.T) {
+	m := newTestMachine()
+	m.CPU.PC = 0xC000
+	m.CPU.SP = 0xD000
+	m.Interrupts.Enable = uint8(interrupt.Timer)
+	m.Interrupts.Flag = uint8(interrupt.Timer)
+	// EI; INC A; INC A
+	loadProgram(m, 0xC000, 0xFB, 0x3C, 0x3C)
+	m.CPU.A = 0
+
+	m.step()
+	if m.CPU.IME {
+		t.Errorf("want: IME = false after EI; got IME = %t", m.CPU.IME)
+	}
+
+	m.step()
+	if !m.CPU.IME {
+		t.Errorf("want: IME = true after next instruction; got IME = %t", m.CPU.IME)
+	}
+
+	m.step()
+	if m.CPU.PC != 0x50 {
+		t.Errorf("want: PC = 0x50; got PC = %x", m.CPU.PC)
+	}
+	if m.CPU.A != 1 {
+		t.Errorf("want: A = 1; got A = %d", m.CPU.A)
+	}
+	if m.Bus.Read(0xCFFE) != 0x02 {
+		t.Errorf("want: return address = c002; got %02x%02x", m.Bus.Read(0xCFFF), m.Bus.Read(0xCFFE))
+	}
+}
+
+func TestEIDICancels(t *testing.T) {
+	m := newTestMachine()
+	m.CPU.PC = 0xC000
+	m.Interrupts.Enable = uint8(interrupt.Timer)
+	m.Interrupts.Flag = uint8(interrupt.Timer)
+	// EI; DI; NOP; NOP
+	loadProgram(m, 0xC000, 0xFB, 0xF3, 0x00, 0x00)
+
+	for i := 0; i < 4; i++ {
+		m.step()
+	}
+
+	if m.CPU.IME || m.CPU.IMEPending {
+		t.Errorf("want: IME = false; got IME = %t, pending = %t", m.CPU.IME, m.CPU.IMEPending)
+	}
+	if m.CPU.PC != 0xC004 {
+		t.Errorf("want: PC = 0xC004; got PC = %x", m.CPU.PC)
+	}
+}
+
+func TestEIHalt(t *testing.T) {
+	m := newTestMachine()
+	m.CPU.PC = 0xC000
+	m.CPU.SP = 0xD000
+	m.Interrupts.Enable = uint8(interrupt.Timer)
+	m.Interrupts.Flag = 0x00
+	// EI; HALT; INC A
+	loadProgram(m, 0xC000, 0xFB, 0x76, 0x3C)
+	m.CPU.A = 0
+
+	m.step()
+	m.step()
+	if !m.CPU.Halt || !m.CPU.IME {
+		t.Fatalf("want: halted with IME; got Halt = %t, IME = %t", m.CPU.Halt, m.CPU.IME)
+	}
+
+	m.Interrupts.Request(interrupt.Timer)
+	m.step()
+
+	if m.CPU.PC != 0x50 {
+		t.Errorf("want: PC = 0x50; got PC = %x", m.CPU.PC)
+	}
+	if m.Bus.Read(0xCFFE) != 0x02 {
+		t.Errorf("want: return address = c002; got %02x%02x", m.Bus.Read(0xCFFF), m.Bus.Read(0xCFFE))
+	}
+}
+
+// With an interrupt already pending, EI; HALT hits the halt bug and the
+// handler returns to the HALT, which then runs again.
+func TestEIHaltPending(t *testing.T) {
+	m := newTestMachine()
+	m.CPU.PC = 0xC000
+	m.CPU.SP = 0xD000
+	m.Interrupts.Enable = uint8(interrupt.Timer)
+	m.Interrupts.Flag = uint8(interrupt.Timer)
+	// EI; HALT; INC A
+	loadProgram(m, 0xC000, 0xFB, 0x76, 0x3C)
+
+	m.step()
+	m.step()
+	m.step()
+
+	if m.CPU.PC != 0x50 {
+		t.Errorf("want: PC = 0x50; got PC = %x", m.CPU.PC)
+	}
+	if m.Bus.Read(0xCFFE) != 0x01 {
+		t.Errorf("want: return address = c001; got %02x%02x", m.Bus.Read(0xCFFF), m.Bus.Read(0xCFFE))
+	}
+	if m.CPU.HaltBug {
+		t.Errorf("want: HaltBug = false; got HaltBug = %t", m.CPU.HaltBug)
+	}
+}
+
+// EI; RET enables interrupts only once RET has jumped, so the handler
+// returns to the RET target.
+func TestEIRet(t *testing.T) {
+	m := newTestMachine()
+	m.CPU.PC = 0xC000
+	m.CPU.SP = 0xCFFE
+	m.Interrupts.Enable = uint8(interrupt.Timer)
+	m.Interrupts.Flag = uint8(interrupt.Timer)
+	// EI; RET
+	loadProgram(m, 0xC000, 0xFB, 0xC9)
+	loadProgram(m, 0xCFFE, 0x34, 0x12)
+
+	m.step()
+	m.step()
+	target := m.CPU.PC
+	m.step()
+
+	if m.CPU.PC != 0x50 {
+		t.Errorf("want: PC = 0x50; got PC = %x", m.CPU.PC)
+	}
+	if m.CPU.SP != 0xCFFE {
+		t.Errorf("want: SP = 0xCFFE; got SP = %x", m.CPU.SP)
+	}
+	if got := uint16(m.Bus.Read(0xCFFF))<<8 | uint16(m.Bus.Read(0xCFFE)); got != target {
+		t.Errorf("want: return address = %04x; got %04x", target, got)
+	}
+}
+
+func TestRETIEnablesImmediately(t *testing.T) {
+	m := newTestMachine()
+	m.CPU.PC = 0xC000
+	m.CPU.SP = 0xCFFE
+	m.Interrupts.Enable = uint8(interrupt.Timer)
+	m.Interrupts.Flag = uint8(interrupt.Timer)
+	// RETI
+	loadProgram(m, 0xC000, 0xD9)
+	loadProgram(m, 0xCFFE, 0x34, 0x12)
+
+	m.step()
+	if !m.CPU.IME {
+		t.Errorf("want: IME = true; got IME = %t", m.CPU.IME)
+	}
+
+	m.step()
+	if m.CPU.PC != 0x50 {
+		t.Errorf("want: PC = 0x50; got PC = %x", m.CPU.PC)
+	}
+}
+
+func TestRETIClearsPendingEI(t *testing.T) {
+	m := newTestMachine()
+	m.CPU.PC = 0xC000
+	m.CPU.SP = 0xCFFE
+	// EI; RETI
+	loadProgram(m, 0xC000, 0xFB, 0xD9)
+	loadProgram(m, 0xCFFE, 0x34, 0x12)
+
+	m.CPU.Execute(m.CPU.Fetch())
+	m.CPU.Execute(m.CPU.Fetch())
+
+	// Otherwise a DI right after would be undone once the next instruction
+	// is done
+	if !m.CPU.IME || m.CPU.IMEPending {
+		t.Errorf("want: IME = true, pending = false; got IME = %t, pending = %t", m.CPU.IME, m.CPU.IMEPending)
+	}
+}
@@ -1,52 +1,256 @@
-package main
+// Package gameboy assembles the components of the Game Boy into an
+// Emulator. The components live in their own packages and can be used on
+// their own, the Emulator wires them on a bus and keeps them in step
+package gameboy
 
-type Memory [0xFFFF]uint8
+import (
+	"github.com/hmatheisen/gameboy/apu"
+	"github.com/hmatheisen/gameboy/bus"
+	"github.com/hmatheisen/gameboy/cartridge"
+	"github.com/hmatheisen/gameboy/cpu"
+	"github.com/hmatheisen/gameboy/interrupt"
+	"github.com/hmatheisen/gameboy/joypad"
+	"github.com/hmatheisen/gameboy/ppu"
+	"github.com/hmatheisen/gameboy/serial"
+	"github.com/hmatheisen/gameboy/timer"
+)
 
-type Gameboy struct {
-	CPU     *CPU
-	Memory  *Memory
-	MCycles int // Machine cycles
+const (
+	// Machine cycles per frame and frames per second
+	CyclesPerFrame = ppu.CyclesPerFrame
+	FrameRate      = ppu.FrameRate
+)
+
+// ErrIllegalOpcode is returned once the CPU hits one of the opcodes which
+// lock it up until reset
+var ErrIllegalOpcode = cpu.ErrIllegalOpcode
+
+type Emulator struct {
+	CPU        *cpu.CPU
+	Bus        bus.Bus
+	Interrupts *interrupt.Interrupts
+	Cartridge  *cartridge.Cartridge
+	GBS        *GBS
+	PPU        *ppu.PPU
+	DMA        *ppu.DMA
+	Timer      *timer.Timer
+	Joypad     *joypad.Joypad
+	APU        *apu.APU
+	Serial     *serial.Serial
+	MCycles    int // Machine cycles
+	Model      Model
+	BootROM    *BootROM
+
+	// Frame for which the joypad was last polled, frames are counted in
+	// cycles so that input keeps coming while the LCD is off
+	polledFrame int
+
+	// What advances the clock of the cartridges inserted
+	rtcSource cartridge.RTCSource
+
+	// OnRumble reports the rumble motor of the cartridge turning on or off
+	OnRumble func(on bool)
+}
+
+// Option configures an Emulator at construction
+type Option func(*Emulator)
+
+// WithRenderer selects how the PPU draws its lines
+func WithRenderer(renderer ppu.Renderer) Option {
+	return func(gb *Emulator) {
+		gb.PPU.Renderer = renderer
+	}
+}
+
+// WithRTCClock selects what advances the clock of MBC3 cartridges, the host
+// clock by default
+func WithRTCClock(source cartridge.RTCSource) Option {
+	return func(gb *Emulator) {
+		gb.rtcSource = source
+	}
 }
 
-func NewGameboy() *Gameboy {
-	gb := new(Gameboy)
+// WithSampleRate sets the audio output rate in Hz, 0 disables audio output
+func WithSampleRate(rate int) Option {
+	return func(gb *Emulator) {
+		gb.APU.SetSampleRate(rate)
+	}
+}
+
+func New(options ...Option) *Emulator {
+	gb := new(Emulator)
+
+	gb.Interrupts = interrupt.New()
+	gb.Bus = bus.NewMemoryBus()
+	gb.CPU = cpu.New(cpuBus{gb}, gb.Interrupts)
+
+	gb.PPU = ppu.New(gb.Interrupts)
+	gb.DMA = ppu.NewDMA(gb.Bus, gb.PPU)
+	gb.Timer = timer.New(gb.Interrupts)
+	gb.Joypad = joypad.New(gb.Interrupts)
+	gb.APU = apu.New(apu.DefaultSampleRate)
+	gb.Serial = serial.New(gb.Interrupts)
+
+	wram := bus.NewRAM(0xC000, 0x2000)
 
-	gb.CPU = NewCPU()
-	gb.Memory = new(Memory)
+	// The cartridge area is left unmapped until a cartridge is inserted
+	gb.Bus.Map(0x8000, 0x9FFF, gb.PPU)
+	gb.Bus.Map(0xC000, 0xDFFF, wram)
+	gb.Bus.Map(0xE000, 0xFDFF, bus.Mirror{Region: wram, Offset: 0x2000})
+	gb.Bus.Map(0xFE00, 0xFE9F, gb.PPU)
+	gb.Bus.Map(0xFEA0, 0xFEFF, bus.Unusable{})
+	// I/O registers not claimed by a subsystem behave as plain memory
+	gb.Bus.Map(0xFF00, 0xFF7F, bus.NewRAM(0xFF00, 0x80))
+	gb.Bus.Map(0xFF00, 0xFF00, gb.Joypad)
+	gb.Bus.Map(0xFF01, 0xFF02, gb.Serial)
+	gb.Bus.Map(0xFF04, 0xFF07, gb.Timer)
+	gb.Bus.Map(0xFF0F, 0xFF0F, gb.Interrupts)
+	gb.Bus.Map(0xFF10, 0xFF3F, gb.APU)
+	gb.Bus.Map(0xFF40, 0xFF45, gb.PPU)
+	gb.Bus.Map(0xFF46, 0xFF46, gb.DMA)
+	gb.Bus.Map(0xFF47, 0xFF4B, gb.PPU)
+	gb.Bus.Map(0xFF80, 0xFFFE, bus.NewRAM(0xFF80, 0x7F))
+	gb.Bus.Map(0xFFFF, 0xFFFF, gb.Interrupts)
 
 	gb.MCycles = 0
+	gb.Model = ModelDMG
+	gb.polledFrame = -1
+
+	for _, option := range options {
+		option(gb)
+	}
 
 	return gb
 }
 
-func (gb *Gameboy) readPC() uint8 {
-	value := gb.readMemory(gb.CPU.PC)
+// InsertCartridge maps the cartridge ROM and external RAM areas on the bus
+func (gb *Emulator) InsertCartridge(cart *cartridge.Cartridge) {
+	gb.Cartridge = cart
+	if cart.RTC != nil {
+		cart.RTC.Source = gb.rtcSource
+	}
+	cart.OnRumble = func(on bool) {
+		if gb.OnRumble != nil {
+			gb.OnRumble(on)
+		}
+	}
 
-	gb.CPU.PC++
+	gb.Bus.Map(0x0000, 0x7FFF, cart)
+	gb.Bus.Map(0xA000, 0xBFFF, cart)
 
-	return value
+	if gb.BootROM != nil && !gb.BootROM.Done {
+		gb.BootROM.cartridge = cart
+		gb.Bus.Map(0x0000, 0x00FF, gb.BootROM)
+	}
 }
 
-func (gb *Gameboy) readSP() uint8 {
-	value := gb.readMemory(gb.CPU.SP)
+// Close persists battery backed cartridge RAM
+func (gb *Emulator) Close() error {
+	if gb.Cartridge == nil {
+		return nil
+	}
+	return gb.Cartridge.Close()
+}
 
-	gb.CPU.SP++
+// Step runs a single instruction, services an interrupt, or idles for a
+// cycle while halted, and returns the number of machine cycles it took
+func (gb *Emulator) Step() (int, error) {
+	start := gb.MCycles
 
-	return value
+	err := gb.step()
+	if err == nil && gb.Cartridge != nil {
+		err = gb.Cartridge.AutoSave()
+	}
+
+	return gb.MCycles - start, err
 }
 
-func (gb *Gameboy) readMemory(addr uint16) uint8 {
-	value := gb.Memory[addr]
+// RunFrame runs until the PPU is done with a frame. While the LCD is off, it
+// runs for as long as a frame takes instead
+func (gb *Emulator) RunFrame() error {
+	frames := gb.PPU.Frames
+	end := gb.MCycles + CyclesPerFrame
 
+	for gb.PPU.Frames == frames && gb.MCycles < end {
+		if _, err := gb.Step(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RunFor runs for at least the given number of machine cycles, stopping at
+// the first instruction boundary past them
+func (gb *Emulator) RunFor(cycles int) error {
+	end := gb.MCycles + cycles
+
+	for gb.MCycles < end {
+		if _, err := gb.Step(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Run runs frame after frame, paced in real time, until stop returns true or
+// the emulation fails
+func (gb *Emulator) Run(pacer *Pacer, stop func() bool) error {
+	for !stop() {
+		if err := gb.RunFrame(); err != nil {
+			return err
+		}
+		pacer.Wait()
+	}
+	return nil
+}
+
+func (gb *Emulator) step() error {
+	return gb.CPU.Step()
+}
+
+// Advances every component by one machine cycle
+func (gb *Emulator) tick() {
 	gb.MCycles++
 
+	gb.DMA.Tick()
+	gb.PPU.Tick()
+	gb.Timer.Tick()
+	gb.APU.Tick()
+	gb.Serial.Tick()
+
+	if frame := (gb.MCycles - 1) / CyclesPerFrame; frame != gb.polledFrame {
+		gb.polledFrame = frame
+		gb.Joypad.Poll(frame)
+	}
+
+	if gb.Cartridge != nil {
+		gb.Cartridge.Tick(1)
+	}
+	if gb.GBS != nil {
+		gb.tickGBS()
+	}
+}
+
+// cpuBus is the bus as the CPU sees it, where every access takes a machine
+// cycle
+type cpuBus struct {
+	gb *Emulator
+}
+
+func (b cpuBus) Read(addr uint16) uint8 {
+	value := b.gb.Bus.Read(addr)
+
+	b.gb.tick()
+
 	return value
 }
 
-func (gb *Gameboy) writeMemory(addr uint16, value uint8) {
-	gb.Memory[addr] = value
+func (b cpuBus) Write(addr uint16, value uint8) {
+	b.gb.Bus.Write(addr, value)
 
-	gb.MCycles++
+	b.gb.tick()
+}
 
-	return
+func (b cpuBus) Tick() {
+	b.gb.tick()
 }
@@ -0,0 +1,331 @@
+package gameboy
+
+import (
+	"errors"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hmatheisen/gameboy/cartridge"
+	"github.com/hmatheisen/gameboy/joypad"
+	"github.com/hmatheisen/gameboy/ppu"
+)
+
+func loadProgram(gb *Emulator, addr uint16, program ...uint8) {
+	for i, b := range program {
+		gb.Bus.Write(addr+uint16(i), b)
+	}
+}
+
+// Builds a 32 KiB ROM only image with valid checksums
+func makeROM() []uint8 {
+	rom := make([]uint8, 0x8000)
+	copy(rom[0x134:], "TEST")
+
+	for i := 0x134; i < 0x14D; i++ {
+		rom[0x14D] -= rom[i] + 1
+	}
+	var sum uint16
+	for _, b := range rom {
+		sum += uint16(b)
+	}
+	rom[0x14E] = uint8(sum >> 8)
+	rom[0x14F] = uint8(sum)
+
+	return rom
+}
+
+func TestStep(t *testing.T) {
+	gb := New()
+	gb.CPU.PC = 0xC000
+	gb.Interrupts.Flag = 0x00
+	// NOP; LD A, [$C100]; INC [HL]
+	loadProgram(gb, 0xC000, 0x00, 0xFA, 0x00, 0xC1, 0x34)
+	gb.CPU.SetHL(0xC100)
+
+	for _, want := range []int{1, 4, 3} {
+		cycles, err := gb.Step()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cycles != want {
+			t.Errorf("want: %d cycles; got %d", want, cycles)
+		}
+	}
+}
+
+func TestRunFrame(t *testing.T) {
+	gb := New()
+	gb.CPU.PC = 0xC000
+	gb.Interrupts.Flag = 0x00
+	loadProgram(gb, 0xC000, 0x76)
+
+	// The first frame ends at the first VBlank, the next ones last a frame
+	if err := gb.RunFrame(); err != nil {
+		t.Fatal(err)
+	}
+	if gb.PPU.Frames != 1 {
+		t.Fatalf("want: 1 frame; got %d", gb.PPU.Frames)
+	}
+
+	start := gb.MCycles
+	if err := gb.RunFrame(); err != nil {
+		t.Fatal(err)
+	}
+	if gb.PPU.Frames != 2 || gb.MCycles-start != CyclesPerFrame {
+		t.Errorf("want: frame 2 after %d cycles; got frame %d after %d", CyclesPerFrame, gb.PPU.Frames, gb.MCycles-start)
+	}
+
+	// With the LCD off frames still last the same
+	gb.PPU.Write(0xFF40, 0x00)
+	start = gb.MCycles
+	if err := gb.RunFrame(); err != nil {
+		t.Fatal(err)
+	}
+	if gb.MCycles-start != CyclesPerFrame {
+		t.Errorf("LCD off: want: %d cycles; got %d", CyclesPerFrame, gb.MCycles-start)
+	}
+}
+
+func TestRunFor(t *testing.T) {
+	gb := New()
+	gb.CPU.PC = 0xC000
+	gb.CPU.SetHL(0xC100)
+	gb.Interrupts.Flag = 0x00
+	// INC [HL] over and over
+	for i := uint16(0); i < 0x40; i++ {
+		loadProgram(gb, 0xC000+i, 0x34)
+	}
+
+	if err := gb.RunFor(10); err != nil {
+		t.Fatal(err)
+	}
+	// Stops at the end of the 4th instruction
+	if gb.MCycles != 12 || gb.Bus.Read(0xC100) != 4 {
+		t.Errorf("want: 12 cycles, 4 increments; got %d cycles, %d increments", gb.MCycles, gb.Bus.Read(0xC100))
+	}
+}
+
+func TestGameboyMemoryMap(t *testing.T) {
+	gb := New()
+
+	gb.Bus.Write(0xC123, 0x42)
+	if gb.Bus.Read(0xE123) != 0x42 {
+		t.Errorf("want: echo RAM = 0x42; got %x", gb.Bus.Read(0xE123))
+	}
+
+	gb.Bus.Write(0xFDFF, 0x24)
+	if gb.Bus.Read(0xDDFF) != 0x24 {
+		t.Errorf("want: WRAM = 0x24; got %x", gb.Bus.Read(0xDDFF))
+	}
+
+	gb.Bus.Write(0xFEA0, 0x12)
+	if gb.Bus.Read(0xFEA0) != 0x00 {
+		t.Errorf("want: unusable = 0x00; got %x", gb.Bus.Read(0xFEA0))
+	}
+
+	gb.Bus.Write(0xFFFE, 0x99)
+	gb.Bus.Write(0xFFFF, 0x1F)
+	if gb.Bus.Read(0xFFFE) != 0x99 {
+		t.Errorf("want: HRAM = 0x99; got %x", gb.Bus.Read(0xFFFE))
+	}
+	if gb.Interrupts.Enable != 0x1F {
+		t.Errorf("want: IE = 0x1F; got %x", gb.Interrupts.Enable)
+	}
+
+	if gb.Bus.Read(0x0100) != 0xFF {
+		t.Errorf("want: no cartridge = 0xFF; got %x", gb.Bus.Read(0x0100))
+	}
+}
+
+func TestIllegalOpcode(t *testing.T) {
+	gb := New()
+	gb.CPU.PC = 0xC000
+	loadProgram(gb, 0xC000, 0x00, 0xDD)
+
+	if _, err := gb.Step(); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		_, err := gb.Step()
+		if !errors.Is(err, ErrIllegalOpcode) {
+			t.Fatalf("want: %v; got %v", ErrIllegalOpcode, err)
+		}
+		if err.Error() != "illegal opcode dd at c001" {
+			t.Errorf("want: opcode and address; got %q", err)
+		}
+	}
+
+	if err := gb.RunFrame(); !errors.Is(err, ErrIllegalOpcode) {
+		t.Errorf("RunFrame: want: %v; got %v", ErrIllegalOpcode, err)
+	}
+}
+
+func TestWithRenderer(t *testing.T) {
+	if gb := New(); gb.PPU.Renderer != ppu.RendererScanline {
+		t.Errorf("want: scanline renderer by default; got %d", gb.PPU.Renderer)
+	}
+	if gb := New(WithRenderer(ppu.RendererFIFO)); gb.PPU.Renderer != ppu.RendererFIFO {
+		t.Errorf("want: FIFO renderer; got %d", gb.PPU.Renderer)
+	}
+}
+
+func TestWithRTCClock(t *testing.T) {
+	rom := makeROM()
+	rom[0x147], rom[0x149] = 0x10, 0x02
+	rom[0x14D] = 0
+	for i := 0x134; i < 0x14D; i++ {
+		rom[0x14D] -= rom[i] + 1
+	}
+
+	for _, source := range []cartridge.RTCSource{cartridge.RTCWallClock, cartridge.RTCEmulated} {
+		cart, err := cartridge.New(rom)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		gb := New(WithRTCClock(source))
+		gb.InsertCartridge(cart)
+		if cart.RTC.Source != source {
+			t.Errorf("want: clock source %d; got %d", source, cart.RTC.Source)
+		}
+	}
+}
+
+// Shades of the reference screenshots shipped with the test ROMs
+var referenceShades = map[uint8]uint8{0xFF: 0, 0xAA: 1, 0x55: 2, 0x00: 3}
+
+// Runs a graphics test ROM until it signals it is done with LD B,B and
+// compares the frame against the expected screenshot
+func runScreenshotTest(t *testing.T, rom, reference string) {
+	cart, err := cartridge.Load(rom, cartridge.WithoutSave())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gb := New(WithRenderer(ppu.RendererFIFO))
+	gb.InsertCartridge(cart)
+
+	done := false
+	gb.CPU.OnBreakpoint = func() {
+		done = true
+	}
+
+	// Frames are counted in cycles, the LCD may well be off
+	end := gb.MCycles + 600*CyclesPerFrame
+	for !done {
+		if gb.MCycles >= end {
+			t.Fatal("timed out waiting for LD B,B")
+		}
+		if err := gb.step(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Let the last frame finish
+	frames := gb.PPU.Frames
+	end = gb.MCycles + CyclesPerFrame
+	for gb.PPU.Frames == frames {
+		if gb.MCycles >= end {
+			t.Fatal("timed out waiting for the last frame, is the LCD off?")
+		}
+		gb.tick()
+	}
+
+	file, err := os.Open(reference)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	img, err := png.Decode(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for y := 0; y < ppu.ScreenHeight; y++ {
+		for x := 0; x < ppu.ScreenWidth; x++ {
+			r, _, _, _ := img.At(x, y).RGBA()
+			want, ok := referenceShades[uint8(r>>8)]
+			if !ok {
+				t.Fatalf("(%d, %d): unexpected reference color %02x", x, y, r>>8)
+			}
+			if got := gb.PPU.Frame[y*ppu.ScreenWidth+x]; got != want {
+				t.Fatalf("(%d, %d): want: shade %d; got shade %d", x, y, want, got)
+			}
+		}
+	}
+}
+
+func TestDMGAcid2(t *testing.T) {
+	dir := filepath.Join("testdata", "dmg-acid2")
+	rom := filepath.Join(dir, "dmg-acid2.gb")
+	if _, err := os.Stat(rom); err != nil {
+		t.Skip("dmg-acid2 not found in testdata")
+	}
+
+	runScreenshotTest(t, rom, filepath.Join(dir, "dmg-acid2-dmg.png"))
+}
+
+// The ROMs of the mealybug-tearoom-tests release go in
+// testdata/mealybug-tearoom, along with the expected directory of the
+// repository holding the screenshots as expected/DMG-blob/<rom>.png
+func TestMealybugTearoom(t *testing.T) {
+	dir := filepath.Join("testdata", "mealybug-tearoom")
+	roms, _ := filepath.Glob(filepath.Join(dir, "*.gb"))
+	if len(roms) == 0 {
+		t.Skip("mealybug-tearoom tests not found in testdata")
+	}
+
+	for _, rom := range roms {
+		name := filepath.Base(rom[:len(rom)-len(filepath.Ext(rom))])
+		t.Run(name, func(t *testing.T) {
+			reference := filepath.Join(dir, "expected", "DMG-blob", name+".png")
+			if _, err := os.Stat(reference); err != nil {
+				t.Skip("no DMG reference screenshot")
+			}
+			runScreenshotTest(t, rom, reference)
+		})
+	}
+}
+
+func TestJoypadPolledEachFrame(t *testing.T) {
+	gb := New()
+	gb.Joypad.Source = &joypad.ScriptedInput{Events: []joypad.InputEvent{
+		{Frame: 1, Buttons: joypad.ButtonStart},
+		{Frame: 2, Buttons: 0},
+	}}
+	gb.Bus.Write(0xFF00, 0x10)
+
+	gb.tick()
+	if got := gb.Bus.Read(0xFF00); got != 0xDF {
+		t.Errorf("frame 0: want: df; got %02x", got)
+	}
+
+	want := []uint8{0xD7, 0xDF}
+	for i, w := range want {
+		for gb.MCycles <= (i+1)*CyclesPerFrame {
+			gb.tick()
+		}
+		if got := gb.Bus.Read(0xFF00); got != w {
+			t.Errorf("frame %d: want: %02x; got %02x", i+1, w, got)
+		}
+	}
+}
+
+func TestJoypadPolledWithLCDOff(t *testing.T) {
+	gb := New()
+	gb.Joypad.Source = &joypad.ScriptedInput{Events: []joypad.InputEvent{
+		{Frame: 1, Buttons: joypad.ButtonStart},
+	}}
+	gb.Bus.Write(0xFF40, 0x00)
+	gb.Bus.Write(0xFF00, 0x10)
+
+	for i := 0; i < 2*CyclesPerFrame; i++ {
+		gb.tick()
+	}
+
+	if got := gb.Bus.Read(0xFF00); got != 0xD7 {
+		t.Errorf("want: d7; got %02x", got)
+	}
+}
@@ -0,0 +1,224 @@
+package gameboy
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/hmatheisen/gameboy/cartridge"
+	"github.com/hmatheisen/gameboy/cpu"
+	"github.com/hmatheisen/gameboy/interrupt"
+)
+
+const (
+	gbsHeaderSize = 0x70
+
+	// Init and play routines return here, where a HALT idles the CPU until
+	// the next play call
+	gbsReturnAddress = 0x0070
+)
+
+var ErrNotGBS = errors.New("not a GBS file")
+
+// GBS is a Game Boy Sound System rip: the sound driver and music data of a
+// game, along with the addresses of the routines starting a song and playing
+// it. The data is mapped as ROM at the load address, and banked past
+// 0x4000 through writes to 0x2000-0x3FFF like MBC1
+type GBS struct {
+	Version      uint8
+	Songs        int
+	FirstSong    int // 1-based
+	LoadAddress  uint16
+	InitAddress  uint16
+	PlayAddress  uint16
+	StackPointer uint16
+	TMA          uint8
+	TAC          uint8
+	Title        string
+	Author       string
+	Copyright    string
+
+	ROM  []byte
+	RAM  []byte
+	bank int
+}
+
+func LoadGBS(path string) (*GBS, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	gbs, err := ParseGBS(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return gbs, nil
+}
+
+func ParseGBS(data []byte) (*GBS, error) {
+	if len(data) < gbsHeaderSize || string(data[0:3]) != "GBS" {
+		return nil, ErrNotGBS
+	}
+
+	gbs := new(GBS)
+
+	gbs.Version = data[0x03]
+	gbs.Songs = int(data[0x04])
+	gbs.FirstSong = int(data[0x05])
+	gbs.LoadAddress = binary.LittleEndian.Uint16(data[0x06:])
+	gbs.InitAddress = binary.LittleEndian.Uint16(data[0x08:])
+	gbs.PlayAddress = binary.LittleEndian.Uint16(data[0x0A:])
+	gbs.StackPointer = binary.LittleEndian.Uint16(data[0x0C:])
+	gbs.TMA = data[0x0E]
+	gbs.TAC = data[0x0F]
+	gbs.Title = gbsString(data[0x10:0x30])
+	gbs.Author = gbsString(data[0x30:0x50])
+	gbs.Copyright = gbsString(data[0x50:0x70])
+
+	if gbs.Version != 1 {
+		return nil, fmt.Errorf("%w: version %d", cartridge.ErrUnsupported, gbs.Version)
+	}
+	if gbs.Songs == 0 || gbs.FirstSong < 1 || gbs.FirstSong > gbs.Songs {
+		return nil, fmt.Errorf("%w: first song %d of %d", cartridge.ErrInconsistent, gbs.FirstSong, gbs.Songs)
+	}
+	if gbs.LoadAddress < 0x400 || gbs.LoadAddress >= 0x8000 {
+		return nil, fmt.Errorf("%w: load address %04x", cartridge.ErrInconsistent, gbs.LoadAddress)
+	}
+
+	code := data[gbsHeaderSize:]
+	size := int(gbs.LoadAddress) + len(code)
+	// Whole 16 KiB banks, at least 2 so that 0x4000-0x7FFF is mapped
+	size = max(2, (size+0x3FFF)/0x4000) * 0x4000
+
+	gbs.ROM = make([]byte, size)
+	copy(gbs.ROM[gbs.LoadAddress:], code)
+	gbs.RAM = make([]byte, 0x2000)
+	gbs.bank = 1
+
+	// RST instructions jump to the same offset from the load address
+	for rst := uint16(0); rst < 0x40; rst += 8 {
+		target := gbs.LoadAddress + rst
+		gbs.ROM[rst] = 0xC3 // JP a16
+		gbs.ROM[rst+1] = uint8(target)
+		gbs.ROM[rst+2] = uint8(target >> 8)
+	}
+	// No interrupt is enabled, but any dispatch returns straight away
+	for vector := 0x40; vector <= 0x60; vector += 8 {
+		gbs.ROM[vector] = 0xD9 // RETI
+	}
+	gbs.ROM[gbsReturnAddress] = 0x76 // HALT
+
+	return gbs, nil
+}
+
+// Strings are padded with zeros
+func gbsString(b []byte) string {
+	if i := bytes.IndexByte(b, 0); i >= 0 {
+		b = b[:i]
+	}
+	return string(b)
+}
+
+// UsesTimer reports whether the play routine runs on the timer interrupt
+// rather than VBlank
+func (g *GBS) UsesTimer() bool {
+	// Bit 2 of TAC enables the timer
+	return g.TAC&0x04 != 0
+}
+
+// Rate returns how many times per second the play routine runs
+func (g *GBS) Rate() float64 {
+	if !g.UsesTimer() {
+		return FrameRate
+	}
+
+	// M-cycles per timer increment for each clock select
+	inputs := [4]int{256, 4, 16, 64}
+	return float64(cpu.CyclesPerSecond) / float64(inputs[g.TAC&0x03]*(256-int(g.TMA)))
+}
+
+func (g *GBS) Read(addr uint16) uint8 {
+	switch {
+	case addr < 0x4000:
+		return g.ROM[addr]
+	case addr < 0x8000:
+		return g.ROM[(g.bank*0x4000+int(addr-0x4000))%len(g.ROM)]
+	default:
+		return g.RAM[addr-0xA000]
+	}
+}
+
+func (g *GBS) Write(addr uint16, value uint8) {
+	switch {
+	case addr >= 0xA000:
+		g.RAM[addr-0xA000] = value
+	case addr >= 0x2000 && addr < 0x4000:
+		g.bank = max(1, int(value))
+	}
+}
+
+// InsertGBS maps a GBS rip in place of a cartridge, ready for PlaySong
+func (gb *Emulator) InsertGBS(gbs *GBS) {
+	gb.GBS = gbs
+
+	gb.Bus.Map(0x0000, 0x7FFF, gbs)
+	gb.Bus.Map(0xA000, 0xBFFF, gbs)
+}
+
+// PlaySong runs the init routine for a song, numbered from 0, after which
+// the play routine gets called at the rate from the GBS header
+func (gb *Emulator) PlaySong(song int) error {
+	gbs := gb.GBS
+	if gbs == nil {
+		return errors.New("gbs: no GBS inserted")
+	}
+	if song < 0 || song >= gbs.Songs {
+		return fmt.Errorf("gbs: song %d out of %d", song, gbs.Songs)
+	}
+
+	clear(gbs.RAM)
+	gbs.bank = 1
+
+	gb.Bus.Write(0xFF06, gbs.TMA)
+	gb.Bus.Write(0xFF07, gbs.TAC)
+	gb.Interrupts.Enable = 0x00
+	gb.Interrupts.Flag = 0x00
+
+	gb.CPU.Reset()
+	gb.CPU.A = uint8(song)
+	gb.CPU.SP = gbs.StackPointer
+	gb.callGBS(gbs.InitAddress)
+
+	return nil
+}
+
+// Calls a routine of the GBS driver, which returns to the HALT at the
+// return address
+func (gb *Emulator) callGBS(addr uint16) {
+	gb.CPU.SP -= 2
+	gb.Bus.Write(gb.CPU.SP, uint8(gbsReturnAddress))
+	gb.Bus.Write(gb.CPU.SP+1, uint8(gbsReturnAddress>>8))
+	gb.CPU.PC = addr
+	gb.CPU.Halt = false
+}
+
+// Calls the play routine when the interrupt it is driven by gets
+// requested, unless the previous call is still running
+func (gb *Emulator) tickGBS() {
+	source := uint8(interrupt.VBlank)
+	if gb.GBS.UsesTimer() {
+		source = uint8(interrupt.Timer)
+	}
+	if gb.Interrupts.Flag&source == 0 {
+		return
+	}
+	gb.Interrupts.Flag &^= source
+
+	if gb.CPU.Halt && gb.CPU.PC == gbsReturnAddress+1 {
+		gb.callGBS(gb.GBS.PlayAddress)
+	}
+}
@@ -0,0 +1,184 @@
+package gameboy
+
+import (
+	"encoding/binary"
+	"errors"
+	"testing"
+
+	"github.com/hmatheisen/gameboy/cartridge"
+)
+
+// Builds a GBS loaded at 0x0400 whose init routine stores the song number
+// in 0xFF81 and starts a note, and whose play routine counts its calls in
+// 0xFF80
+func makeGBS(tma, tac uint8) []byte {
+	data := make([]byte, gbsHeaderSize)
+	copy(data, "GBS")
+	data[0x03] = 1
+	data[0x04] = 3
+	data[0x05] = 1
+	binary.LittleEndian.PutUint16(data[0x06:], 0x0400)
+	binary.LittleEndian.PutUint16(data[0x08:], 0x0400)
+	binary.LittleEndian.PutUint16(data[0x0A:], 0x0420)
+	binary.LittleEndian.PutUint16(data[0x0C:], 0xDFFF)
+	data[0x0E] = tma
+	data[0x0F] = tac
+	copy(data[0x10:], "Test Song")
+	copy(data[0x30:], "Nobody")
+	copy(data[0x50:], "2024")
+
+	code := make([]byte, 0x4000)
+	copy(code, []byte{
+		0xE0, 0x81, // LDH [$81], A
+		0x3E, 0x80, 0xE0, 0x26, // NR52
+		0x3E, 0x77, 0xE0, 0x24, // NR50
+		0x3E, 0xFF, 0xE0, 0x25, // NR51
+		0x3E, 0xF0, 0xE0, 0x17, // NR22
+		0x3E, 0x87, 0xE0, 0x19, // NR24
+		0xC9, // RET
+	})
+	copy(code[0x20:], []byte{
+		0xF0, 0x80, // LDH A, [$80]
+		0x3C,       // INC A
+		0xE0, 0x80, // LDH [$80], A
+		0xC9, // RET
+	})
+	// Marks the start of bank 1
+	code[0x4000-0x0400] = 0x42
+
+	return append(data, code...)
+}
+
+func runGBS(gb *Emulator, frames int) {
+	end := gb.MCycles + frames*CyclesPerFrame
+	for gb.MCycles < end {
+		gb.step()
+	}
+}
+
+func TestParseGBS(t *testing.T) {
+	gbs, err := ParseGBS(makeGBS(0, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if gbs.Title != "Test Song" || gbs.Author != "Nobody" || gbs.Copyright != "2024" {
+		t.Errorf("want: strings from the header; got %q, %q, %q", gbs.Title, gbs.Author, gbs.Copyright)
+	}
+	if gbs.Songs != 3 || gbs.FirstSong != 1 || gbs.PlayAddress != 0x0420 {
+		t.Errorf("want: 3 songs from 1, play at 0420; got %d from %d, play at %04x", gbs.Songs, gbs.FirstSong, gbs.PlayAddress)
+	}
+
+	// RST $08 jumps to the load address + 8
+	if gbs.Read(0x0008) != 0xC3 || gbs.Read(0x0009) != 0x08 || gbs.Read(0x000A) != 0x04 {
+		t.Errorf("want: JP $0408 at $0008; got %02x %02x %02x", gbs.Read(0x0008), gbs.Read(0x0009), gbs.Read(0x000A))
+	}
+
+	tests := []struct {
+		name string
+		edit func(data []byte)
+		err  error
+	}{
+		{"magic", func(data []byte) { data[0] = 'X' }, ErrNotGBS},
+		{"version", func(data []byte) { data[0x03] = 2 }, cartridge.ErrUnsupported},
+		{"first song", func(data []byte) { data[0x05] = 4 }, cartridge.ErrInconsistent},
+		{"load address", func(data []byte) { data[0x07] = 0x00 }, cartridge.ErrInconsistent},
+	}
+	for _, tt := range tests {
+		data := makeGBS(0, 0)
+		tt.edit(data)
+		if _, err := ParseGBS(data); !errors.Is(err, tt.err) {
+			t.Errorf("%s: want: %v; got %v", tt.name, tt.err, err)
+		}
+	}
+}
+
+func TestGBSBanking(t *testing.T) {
+	gbs, err := ParseGBS(makeGBS(0, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gbs.Write(0x2000, 0)
+	if gbs.Read(0x4000) != 0x42 {
+		t.Errorf("bank 0: want: bank 1 mapped; got %02x", gbs.Read(0x4000))
+	}
+	// Out of range banks wrap around
+	gbs.Write(0x2000, 2)
+	if gbs.Read(0x4000) != 0xC3 {
+		t.Errorf("bank 2: want: bank 0 mapped; got %02x", gbs.Read(0x4000))
+	}
+
+	gbs.Write(0xA123, 0x55)
+	if gbs.Read(0xA123) != 0x55 {
+		t.Errorf("want: RAM at A000-BFFF")
+	}
+}
+
+func TestGBSRate(t *testing.T) {
+	tests := []struct {
+		tma, tac uint8
+		rate     float64
+	}{
+		{0x00, 0x00, FrameRate},
+		{0x00, 0x04, 16},
+		{0xC0, 0x06, 1024},
+	}
+	for _, tt := range tests {
+		gbs, err := ParseGBS(makeGBS(tt.tma, tt.tac))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := gbs.Rate(); got != tt.rate {
+			t.Errorf("TMA %02x, TAC %02x: want: %f Hz; got %f Hz", tt.tma, tt.tac, tt.rate, got)
+		}
+	}
+}
+
+func TestGBSPlay(t *testing.T) {
+	tests := []struct {
+		name     string
+		tma, tac uint8
+		calls    int
+	}{
+		{"VBlank", 0x00, 0x00, 60},
+		{"timer", 0x00, 0x04, 16},
+	}
+
+	for _, tt := range tests {
+		gbs, err := ParseGBS(makeGBS(tt.tma, tt.tac))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		gb := New()
+		gb.InsertGBS(gbs)
+		if err := gb.PlaySong(2); err != nil {
+			t.Fatal(err)
+		}
+		runGBS(gb, 60)
+
+		if got := gb.Bus.Read(0xFF81); got != 2 {
+			t.Errorf("%s: want: song 2 passed in A; got %d", tt.name, got)
+		}
+		if got := int(gb.Bus.Read(0xFF80)); got < tt.calls-1 || got > tt.calls {
+			t.Errorf("%s: want: about %d play calls; got %d", tt.name, tt.calls, got)
+		}
+		if gb.APU.Read(0xFF26)&0x02 == 0 {
+			t.Errorf("%s: want: square 2 playing", tt.name)
+		}
+		if !gb.CPU.Halt || gb.CPU.PC != gbsReturnAddress+1 {
+			t.Errorf("%s: want: idling at the return address; got PC = %04x, Halt = %t", tt.name, gb.CPU.PC, gb.CPU.Halt)
+		}
+	}
+
+	gb := New()
+	if err := gb.PlaySong(0); err == nil {
+		t.Errorf("want: error without a GBS")
+	}
+	gbs, _ := ParseGBS(makeGBS(0, 0))
+	gb.InsertGBS(gbs)
+	if err := gb.PlaySong(3); err == nil {
+		t.Errorf("want: error for songs out of range")
+	}
+}
@@ -0,0 +1,60 @@
+// Package interrupt holds the IE and IF registers, shared by the CPU and the
+// components requesting interrupts
+package interrupt
+
+import "math/bits"
+
+type Interrupt uint8
+
+// Interrupt sources, in priority order, as laid out in the IE and IF registers
+const (
+	VBlank Interrupt = 1 << iota
+	LCD
+	Timer
+	Serial
+	Joypad
+)
+
+func (i Interrupt) Vector() uint16 {
+	return 0x40 + 8*uint16(bits.TrailingZeros8(uint8(i)))
+}
+
+// Interrupts holds the IE (0xFFFF) and IF (0xFF0F) registers
+type Interrupts struct {
+	Enable uint8
+	Flag   uint8
+}
+
+func New() *Interrupts {
+	interrupts := new(Interrupts)
+
+	interrupts.Enable = 0x00
+	interrupts.Flag = 0x01
+
+	return interrupts
+}
+
+func (i *Interrupts) Request(interrupt Interrupt) {
+	i.Flag |= uint8(interrupt)
+}
+
+// Pending returns the interrupts both requested and enabled
+func (i *Interrupts) Pending() uint8 {
+	return i.Enable & i.Flag & 0x1F
+}
+
+// Only the lower 5 bits of IF exist, the others always read as 1
+func (i *Interrupts) Read(addr uint16) uint8 {
+	if addr == 0xFFFF {
+		return i.Enable
+	}
+	return i.Flag | 0xE0
+}
+
+func (i *Interrupts) Write(addr uint16, value uint8) {
+	if addr == 0xFFFF {
+		i.Enable = value
+	} else {
+		i.Flag = value & 0x1F
+	}
+}
@@ -0,0 +1,41 @@
+package interrupt
+
+import "testing"
+
+func TestInterruptVectors(t *testing.T) {
+	tests := []struct {
+		interrupt Interrupt
+		vector    uint16
+	}{
+		{VBlank, 0x40},
+		{LCD, 0x48},
+		{Timer, 0x50},
+		{Serial, 0x58},
+		{Joypad, 0x60},
+	}
+
+	for _, tt := range tests {
+		if tt.interrupt.Vector() != tt.vector {
+			t.Errorf("want: vector = %x; got vector = %x", tt.vector, tt.interrupt.Vector())
+		}
+	}
+}
+
+func TestInterruptRegisters(t *testing.T) {
+	interrupts := New()
+
+	interrupts.Write(0xFFFF, 0x1F)
+	interrupts.Write(0xFF0F, 0x04)
+
+	if interrupts.Read(0xFFFF) != 0x1F {
+		t.Errorf("want: IE = 0x1F; got IE = %x", interrupts.Read(0xFFFF))
+	}
+	if interrupts.Read(0xFF0F) != 0xE4 {
+		t.Errorf("want: IF = 0xE4; got IF = %x", interrupts.Read(0xFF0F))
+	}
+
+	interrupts.Request(Joypad)
+	if interrupts.Read(0xFF0F) != 0xF4 {
+		t.Errorf("want: IF = 0xF4; got IF = %x", interrupts.Read(0xFF0F))
+	}
+}
@@ -0,0 +1,157 @@
+package joypad
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// InputSource drives the joypad, it is polled once per frame with the
+// number of the frame about to be emulated
+type InputSource interface {
+	Poll(frame int) Buttons
+}
+
+var buttonNames = []string{"RIGHT", "LEFT", "UP", "DOWN", "A", "B", "SELECT", "START"}
+
+func (b Buttons) String() string {
+	if b == 0 {
+		return "NONE"
+	}
+
+	var names []string
+	for i, name := range buttonNames {
+		if b&(1<<i) != 0 {
+			names = append(names, name)
+		}
+	}
+	return strings.Join(names, "+")
+}
+
+// ParseButtons reads buttons written as by Buttons.String, such as "A+START"
+func ParseButtons(s string) (Buttons, error) {
+	if s == "NONE" {
+		return 0, nil
+	}
+
+	var buttons Buttons
+	for _, name := range strings.Split(s, "+") {
+		i := slices.Index(buttonNames, strings.ToUpper(name))
+		if i < 0 {
+			return 0, fmt.Errorf("unknown button %q", name)
+		}
+		buttons |= 1 << i
+	}
+	return buttons, nil
+}
+
+// ManualInput holds buttons set from another goroutine, such as the event
+// loop of a keyboard frontend
+type ManualInput struct {
+	mu      sync.Mutex
+	buttons Buttons
+}
+
+func (m *ManualInput) Press(buttons Buttons) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.buttons |= buttons
+}
+
+func (m *ManualInput) Release(buttons Buttons) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.buttons &^= buttons
+}
+
+func (m *ManualInput) Poll(frame int) Buttons {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.buttons
+}
+
+// InputEvent sets the pressed buttons from the given frame onwards
+type InputEvent struct {
+	Frame   int
+	Buttons Buttons
+}
+
+// ScriptedInput replays input events, sorted by frame
+type ScriptedInput struct {
+	Events []InputEvent
+}
+
+func (s *ScriptedInput) Poll(frame int) Buttons {
+	var buttons Buttons
+	for _, event := range s.Events {
+		if event.Frame > frame {
+			break
+		}
+		buttons = event.Buttons
+	}
+	return buttons
+}
+
+// ReadReplay parses a replay file, made of one "<frame> <buttons>" event per
+// line. Blank lines and lines starting with # are ignored
+func ReadReplay(r io.Reader) (*ScriptedInput, error) {
+	script := new(ScriptedInput)
+
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		fields := strings.Fields(text)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("replay line %d: want frame and buttons", line)
+		}
+		frame, err := strconv.Atoi(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("replay line %d: %w", line, err)
+		}
+		buttons, err := ParseButtons(fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("replay line %d: %w", line, err)
+		}
+		if n := len(script.Events); n > 0 && script.Events[n-1].Frame > frame {
+			return nil, fmt.Errorf("replay line %d: frame %d out of order", line, frame)
+		}
+
+		script.Events = append(script.Events, InputEvent{Frame: frame, Buttons: buttons})
+	}
+
+	return script, scanner.Err()
+}
+
+func WriteReplay(w io.Writer, events []InputEvent) error {
+	for _, event := range events {
+		if _, err := fmt.Fprintf(w, "%d %s\n", event.Frame, event.Buttons); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// InputRecorder passes input through from a source while recording every
+// change, to be written as a replay
+type InputRecorder struct {
+	Source InputSource
+	Events []InputEvent
+}
+
+func (r *InputRecorder) Poll(frame int) Buttons {
+	buttons := r.Source.Poll(frame)
+
+	n := len(r.Events)
+	if n == 0 && buttons != 0 || n > 0 && r.Events[n-1].Buttons != buttons {
+		r.Events = append(r.Events, InputEvent{Frame: frame, Buttons: buttons})
+	}
+	return buttons
+}
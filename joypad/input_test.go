@@ -0,0 +1,105 @@
+package joypad
+
+import (
+	"bytes"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestButtonsString(t *testing.T) {
+	tests := []struct {
+		buttons Buttons
+		want    string
+	}{
+		{0, "NONE"},
+		{ButtonA, "A"},
+		{ButtonUp | ButtonB | ButtonStart, "UP+B+START"},
+	}
+	for _, tt := range tests {
+		if got := tt.buttons.String(); got != tt.want {
+			t.Errorf("want: %s; got %s", tt.want, got)
+		}
+		if got, err := ParseButtons(tt.want); err != nil || got != tt.buttons {
+			t.Errorf("parse %s: want: %02x; got %02x, %v", tt.want, tt.buttons, got, err)
+		}
+	}
+
+	if _, err := ParseButtons("A+TURBO"); err == nil {
+		t.Errorf("want: error for unknown buttons")
+	}
+}
+
+func TestManualInput(t *testing.T) {
+	var m ManualInput
+	m.Press(ButtonA | ButtonLeft)
+	m.Release(ButtonLeft)
+
+	if got := m.Poll(0); got != ButtonA {
+		t.Errorf("want: A; got %s", got)
+	}
+}
+
+func TestScriptedInput(t *testing.T) {
+	s := &ScriptedInput{Events: []InputEvent{
+		{Frame: 10, Buttons: ButtonA},
+		{Frame: 20, Buttons: ButtonA | ButtonB},
+		{Frame: 30, Buttons: 0},
+	}}
+
+	tests := []struct {
+		frame int
+		want  Buttons
+	}{
+		{0, 0},
+		{10, ButtonA},
+		{19, ButtonA},
+		{25, ButtonA | ButtonB},
+		{100, 0},
+	}
+	for _, tt := range tests {
+		if got := s.Poll(tt.frame); got != tt.want {
+			t.Errorf("frame %d: want: %s; got %s", tt.frame, tt.want, got)
+		}
+	}
+}
+
+func TestReplayRoundTrip(t *testing.T) {
+	recorder := &InputRecorder{Source: &ScriptedInput{Events: []InputEvent{
+		{Frame: 3, Buttons: ButtonStart},
+		{Frame: 5, Buttons: ButtonStart},
+		{Frame: 8, Buttons: 0},
+	}}}
+	for frame := 0; frame < 10; frame++ {
+		recorder.Poll(frame)
+	}
+
+	want := []InputEvent{{Frame: 3, Buttons: ButtonStart}, {Frame: 8, Buttons: 0}}
+	if !slices.Equal(recorder.Events, want) {
+		t.Fatalf("want: %v; got %v", want, recorder.Events)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteReplay(&buf, recorder.Events); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != "3 START\n8 NONE\n" {
+		t.Errorf("want: replay file; got %q", buf.String())
+	}
+
+	script, err := ReadReplay(strings.NewReader("# title screen\n" + buf.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(script.Events, want) {
+		t.Errorf("want: %v; got %v", want, script.Events)
+	}
+}
+
+func TestReadReplayErrors(t *testing.T) {
+	for _, replay := range []string{"10", "x A", "10 JUMP", "10 A\n5 B"} {
+		if _, err := ReadReplay(strings.NewReader(replay)); err == nil {
+			t.Errorf("%q: want: error", replay)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+// Package joypad emulates the button matrix, along with the sources feeding
+// it input: manual presses, scripts, replays and recordings
+package joypad
+
+import "github.com/hmatheisen/gameboy/interrupt"
+
+// Buttons is a set of pressed buttons
+type Buttons uint8
+
+const (
+	ButtonRight Buttons = 1 << iota
+	ButtonLeft
+	ButtonUp
+	ButtonDown
+	ButtonA
+	ButtonB
+	ButtonSelect
+	ButtonStart
+)
+
+const (
+	selectDirections = 1 << 4
+	selectActions    = 1 << 5
+)
+
+// Joypad is the P1/JOYP register (0xFF00). Games pull bit 4 low to read the
+// directions or bit 5 low to read the action buttons on bits 0-3, where a
+// pressed button reads as 0
+type Joypad struct {
+	// Source is polled once per frame, when nil the buttons are left as set
+	// by SetButtons
+	Source InputSource
+
+	pressed Buttons
+	selects uint8
+
+	interrupts *interrupt.Interrupts
+}
+
+func New(interrupts *interrupt.Interrupts) *Joypad {
+	joypad := new(Joypad)
+
+	joypad.interrupts = interrupts
+	joypad.selects = selectDirections | selectActions
+
+	return joypad
+}
+
+// Poll reads the buttons from the input source for the given frame
+func (j *Joypad) Poll(frame int) {
+	if j.Source != nil {
+		j.SetButtons(j.Source.Poll(frame))
+	}
+}
+
+func (j *Joypad) Buttons() Buttons {
+	return j.pressed
+}
+
+// SetButtons changes the pressed buttons, requesting the joypad interrupt
+// when one of the selected lines goes low
+func (j *Joypad) SetButtons(buttons Buttons) {
+	j.update(func() { j.pressed = buttons })
+}
+
+func (j *Joypad) update(change func()) {
+	before := j.lines()
+	change()
+	if before&^j.lines() != 0 {
+		j.interrupts.Request(interrupt.Joypad)
+	}
+}
+
+// Input lines on bits 0-3, high when released
+func (j *Joypad) lines() uint8 {
+	var low uint8
+	if j.selects&selectDirections == 0 {
+		low |= uint8(j.pressed) & 0x0F
+	}
+	if j.selects&selectActions == 0 {
+		low |= uint8(j.pressed) >> 4
+	}
+	return ^low & 0x0F
+}
+
+func (j *Joypad) Read(addr uint16) uint8 {
+	return 0xC0 | j.selects | j.lines()
+}
+
+func (j *Joypad) Write(addr uint16, value uint8) {
+	j.update(func() { j.selects = value & (selectDirections | selectActions) })
+}
@@ -0,0 +1,64 @@
+package joypad
+
+import (
+	"testing"
+
+	"github.com/hmatheisen/gameboy/interrupt"
+)
+
+func newTestJoypad() *Joypad {
+	joypad := New(interrupt.New())
+	joypad.interrupts.Flag = 0x00
+
+	return joypad
+}
+
+func TestJoypadSelect(t *testing.T) {
+	j := newTestJoypad()
+	j.SetButtons(ButtonDown | ButtonA | ButtonStart)
+
+	tests := []struct {
+		selects uint8
+		want    uint8
+	}{
+		{0x30, 0xFF},
+		{0x20, 0xE7},
+		{0x10, 0xD6},
+		{0x00, 0xC6},
+	}
+	for _, tt := range tests {
+		j.Write(0xFF00, tt.selects)
+		if got := j.Read(0xFF00); got != tt.want {
+			t.Errorf("select %02x: want: %02x; got %02x", tt.selects, tt.want, got)
+		}
+	}
+}
+
+func TestJoypadInterrupt(t *testing.T) {
+	j := newTestJoypad()
+	j.Write(0xFF00, 0x20)
+
+	// Action buttons are not selected
+	j.SetButtons(ButtonA)
+	if j.interrupts.Flag != 0 {
+		t.Errorf("unselected: want: no interrupt; got IF = %02x", j.interrupts.Flag)
+	}
+
+	j.SetButtons(ButtonA | ButtonLeft)
+	if j.interrupts.Flag != uint8(interrupt.Joypad) {
+		t.Errorf("press: want: joypad interrupt; got IF = %02x", j.interrupts.Flag)
+	}
+
+	j.interrupts.Flag = 0
+	j.SetButtons(0)
+	if j.interrupts.Flag != 0 {
+		t.Errorf("release: want: no interrupt; got IF = %02x", j.interrupts.Flag)
+	}
+
+	// Selecting a line with a button held pulls it low
+	j.SetButtons(ButtonB)
+	j.Write(0xFF00, 0x10)
+	if j.interrupts.Flag != uint8(interrupt.Joypad) {
+		t.Errorf("select: want: joypad interrupt; got IF = %02x", j.interrupts.Flag)
+	}
+}
@@ -0,0 +1,129 @@
+package gameboy
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/hmatheisen/gameboy/bus"
+)
+
+// Model is a Game Boy hardware revision. They run the same games but their
+// boot ROMs leave different values in the registers, which some games and
+// test ROMs use to detect the model
+type Model int
+
+const (
+	ModelDMG0 Model = iota
+	ModelDMG
+	ModelMGB
+	ModelSGB
+	ModelSGB2
+)
+
+var (
+	allModels  = []Model{ModelDMG0, ModelDMG, ModelMGB, ModelSGB, ModelSGB2}
+	modelNames = []string{"dmg0", "dmg", "mgb", "sgb", "sgb2"}
+)
+
+func (m Model) String() string {
+	if m < 0 || int(m) >= len(modelNames) {
+		return fmt.Sprintf("Model(%d)", int(m))
+	}
+	return modelNames[m]
+}
+
+func ParseModel(name string) (Model, error) {
+	i := slices.Index(modelNames, strings.ToLower(name))
+	if i < 0 {
+		return 0, fmt.Errorf("unknown model %q", name)
+	}
+	return Model(i), nil
+}
+
+// Registers as left by the boot ROM of each model, in A, F, B, C, D, E, H, L
+// order
+var bootRegisters = map[Model][8]uint8{
+	ModelDMG0: {0x01, 0x00, 0xFF, 0x13, 0x00, 0xC1, 0x84, 0x03},
+	ModelDMG:  {0x01, 0xB0, 0x00, 0x13, 0x00, 0xD8, 0x01, 0x4D},
+	ModelMGB:  {0xFF, 0xB0, 0x00, 0x13, 0x00, 0xD8, 0x01, 0x4D},
+	ModelSGB:  {0x01, 0x00, 0x00, 0x14, 0x00, 0x00, 0xC0, 0x60},
+	ModelSGB2: {0xFF, 0x00, 0x00, 0x14, 0x00, 0x00, 0xC0, 0x60},
+}
+
+// WithModel sets the CPU registers as the boot ROM of the model leaves them,
+// unless a boot ROM is run
+func WithModel(model Model) Option {
+	return func(gb *Emulator) {
+		gb.Model = model
+		if gb.BootROM != nil {
+			return
+		}
+
+		r := bootRegisters[model]
+		gb.CPU.A, gb.CPU.F = r[0], r[1]
+		gb.CPU.B, gb.CPU.C = r[2], r[3]
+		gb.CPU.D, gb.CPU.E = r[4], r[5]
+		gb.CPU.H, gb.CPU.L = r[6], r[7]
+	}
+}
+
+// BootROM overlays the first 256 bytes of the cartridge until the boot
+// program writes to 0xFF50
+type BootROM struct {
+	Data []byte
+	Done bool
+
+	bus bus.Bus
+	// What the boot ROM hides, mapped back once it is done
+	cartridge bus.Region
+}
+
+// WithBootROM starts from a boot ROM instead of the state it leaves behind
+func WithBootROM(data []byte) Option {
+	return func(gb *Emulator) {
+		gb.BootROM = &BootROM{Data: data, bus: gb.Bus, cartridge: bus.OpenBus{}}
+
+		c := gb.CPU
+		c.A, c.F, c.B, c.C = 0, 0, 0, 0
+		c.D, c.E, c.H, c.L = 0, 0, 0, 0
+		c.PC, c.SP = 0x0000, 0x0000
+		gb.Timer.SetDivider(0)
+		gb.Bus.Map(0x0000, 0x00FF, gb.BootROM)
+		gb.Bus.Map(0xFF50, 0xFF50, gb.BootROM)
+	}
+}
+
+var ErrBootROMSize = errors.New("boot ROM must be 256 bytes")
+
+func LoadBootROM(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) != 0x100 {
+		return nil, fmt.Errorf("%s: %w", path, ErrBootROMSize)
+	}
+	return data, nil
+}
+
+func (b *BootROM) Read(addr uint16) uint8 {
+	if addr == 0xFF50 {
+		return 0xFF
+	}
+	// Past the end of a short image, as given to WithBootROM directly
+	if int(addr) >= len(b.Data) {
+		return 0xFF
+	}
+	return b.Data[addr]
+}
+
+func (b *BootROM) Write(addr uint16, value uint8) {
+	if addr == 0xFF50 && value != 0 {
+		b.Done = true
+		b.bus.Map(0x0000, 0x00FF, b.cartridge)
+		b.bus.Map(0xFF50, 0xFF50, bus.OpenBus{})
+	}
+}
@@ -0,0 +1,92 @@
+package gameboy
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hmatheisen/gameboy/cartridge"
+)
+
+func TestParseModel(t *testing.T) {
+	for _, name := range modelNames {
+		model, err := ParseModel(name)
+		if err != nil || model.String() != name {
+			t.Errorf("%s: want: round trip; got %v, %v", name, model, err)
+		}
+	}
+	if model, err := ParseModel("SGB2"); err != nil || model != ModelSGB2 {
+		t.Errorf("want: names ignore case; got %v, %v", model, err)
+	}
+	if _, err := ParseModel("cgb"); err == nil {
+		t.Errorf("want: error for unknown models")
+	}
+	if got := Model(42).String(); got != "Model(42)" {
+		t.Errorf("want: Model(42); got %s", got)
+	}
+}
+
+func TestWithModel(t *testing.T) {
+	gb := New(WithModel(ModelMGB))
+	if gb.Model != ModelMGB || gb.CPU.A != 0xFF {
+		t.Errorf("want: MGB with A = FF; got %s with A = %02x", gb.Model, gb.CPU.A)
+	}
+
+	gb = New(WithModel(ModelDMG0))
+	if gb.CPU.B != 0xFF || gb.CPU.HL() != 0x8403 {
+		t.Errorf("want: DMG0 registers; got B = %02x, HL = %04x", gb.CPU.B, gb.CPU.HL())
+	}
+}
+
+func TestBootROM(t *testing.T) {
+	boot := make([]uint8, 0x100)
+	// LD A, 1; LDH [$50], A
+	copy(boot, []uint8{0x3E, 0x01, 0xE0, 0x50})
+
+	// The model is kept, but the boot ROM sets up the registers itself
+	gb := New(WithBootROM(boot), WithModel(ModelMGB))
+	cart, err := cartridge.New(makeROM())
+	if err != nil {
+		t.Fatal(err)
+	}
+	gb.InsertCartridge(cart)
+
+	if gb.CPU.PC != 0x0000 || gb.CPU.A != 0x00 || gb.Model != ModelMGB {
+		t.Errorf("want: start at 0000 with A = 00; got PC = %04x, A = %02x", gb.CPU.PC, gb.CPU.A)
+	}
+	if got := gb.Bus.Read(0x0000); got != 0x3E {
+		t.Errorf("want: boot ROM mapped over the cartridge; got %02x", got)
+	}
+
+	for i := 0; i < 2; i++ {
+		gb.step()
+	}
+
+	if got := gb.Bus.Read(0x0000); got != 0x00 || !gb.BootROM.Done {
+		t.Errorf("want: cartridge mapped back after writing to FF50; got %02x", got)
+	}
+	if got := gb.Bus.Read(0x0134); got != 'T' {
+		t.Errorf("want: cartridge header untouched; got %02x", got)
+	}
+}
+
+func TestShortBootROM(t *testing.T) {
+	// NOP
+	gb := New(WithBootROM([]uint8{0x00}))
+
+	if got := gb.Bus.Read(0x0080); got != 0xFF {
+		t.Errorf("want: ff past the end of the boot ROM; got %02x", got)
+	}
+}
+
+func TestLoadBootROM(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "boot.bin")
+	if err := os.WriteFile(path, make([]byte, 0x80), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := LoadBootROM(path); !errors.Is(err, ErrBootROMSize) {
+		t.Errorf("want: %v; got %v", ErrBootROMSize, err)
+	}
+}
@@ -0,0 +1,63 @@
+package gameboy
+
+import "time"
+
+// Behind schedule by more than this, the pacer gives up catching up
+const maxPacerLag = 100 * time.Millisecond
+
+// Pacer keeps emulation in step with real time, at FrameRate frames per
+// second scaled by a speed multiplier
+type Pacer struct {
+	speed  float64
+	start  time.Time
+	frames int
+
+	now   func() time.Time
+	sleep func(time.Duration)
+}
+
+func NewPacer() *Pacer {
+	pacer := new(Pacer)
+
+	pacer.now = time.Now
+	pacer.sleep = time.Sleep
+	pacer.SetSpeed(1)
+
+	return pacer
+}
+
+func (p *Pacer) Speed() float64 {
+	return p.speed
+}
+
+// SetSpeed sets the multiplier over real time, above 1 for turbo and below
+// for slow motion. A speed of 0 runs as fast as possible
+func (p *Pacer) SetSpeed(speed float64) {
+	p.speed = speed
+	p.reset()
+}
+
+func (p *Pacer) reset() {
+	p.start = p.now()
+	p.frames = 0
+}
+
+// Wait is called after every frame, and sleeps until it is time for the next
+// one
+func (p *Pacer) Wait() {
+	if p.speed <= 0 {
+		return
+	}
+
+	p.frames++
+	elapsed := time.Duration(float64(p.frames) / FrameRate / p.speed * float64(time.Second))
+	delay := p.start.Add(elapsed).Sub(p.now())
+
+	switch {
+	case delay > 0:
+		p.sleep(delay)
+	case delay < -maxPacerLag:
+		// Running late after a stall, start over rather than rushing
+		p.reset()
+	}
+}
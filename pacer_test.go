@@ -0,0 +1,75 @@
+package gameboy
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hmatheisen/gameboy/cpu"
+)
+
+// Returns a pacer running on a fake clock, which advances when it sleeps
+func newTestPacer() (*Pacer, *time.Time) {
+	now := time.Unix(0, 0)
+
+	pacer := NewPacer()
+	pacer.now = func() time.Time { return now }
+	pacer.sleep = func(d time.Duration) { now = now.Add(d) }
+	pacer.SetSpeed(1)
+
+	return pacer, &now
+}
+
+func TestPacerSpeeds(t *testing.T) {
+	tests := []struct {
+		speed float64
+		want  time.Duration
+	}{
+		{1, time.Second},
+		{2, time.Second / 2},
+		{0.5, 2 * time.Second},
+	}
+
+	for _, tt := range tests {
+		pacer, now := newTestPacer()
+		start := *now
+		pacer.SetSpeed(tt.speed)
+
+		frames := int(100 * cpu.CyclesPerSecond / CyclesPerFrame)
+		for i := 0; i < frames; i++ {
+			pacer.Wait()
+		}
+
+		// Within a frame of the expected time
+		got := now.Sub(start) / 100
+		if diff := got - tt.want; diff < -time.Second/60 || diff > time.Second/60 {
+			t.Errorf("speed %.1f: want: %v per second of frames; got %v", tt.speed, tt.want, got)
+		}
+	}
+}
+
+func TestPacerUnthrottled(t *testing.T) {
+	pacer, now := newTestPacer()
+	start := *now
+	pacer.SetSpeed(0)
+
+	for i := 0; i < 1000; i++ {
+		pacer.Wait()
+	}
+	if *now != start {
+		t.Errorf("want: no sleeping; got %v", now.Sub(start))
+	}
+}
+
+func TestPacerGivesUpWhenLate(t *testing.T) {
+	pacer, now := newTestPacer()
+
+	// A stall of a second, the next frames are not rushed
+	*now = now.Add(time.Second)
+	pacer.Wait()
+
+	before := *now
+	pacer.Wait()
+	if got := now.Sub(before); got < 16*time.Millisecond {
+		t.Errorf("want: a full frame wait after the stall; got %v", got)
+	}
+}
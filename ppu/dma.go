@@ -0,0 +1,55 @@
+package ppu
+
+import "github.com/hmatheisen/gameboy/bus"
+
+// DMA copies 160 bytes to OAM, one byte per machine cycle, from the page
+// written to 0xFF46
+type DMA struct {
+	source uint16
+	index  int
+	active bool
+	value  uint8
+
+	bus bus.Bus
+	ppu *PPU
+}
+
+func NewDMA(b bus.Bus, ppu *PPU) *DMA {
+	dma := new(DMA)
+
+	dma.bus = b
+	dma.ppu = ppu
+	dma.value = 0xFF
+
+	return dma
+}
+
+func (d *DMA) Read(addr uint16) uint8 {
+	return d.value
+}
+
+func (d *DMA) Write(addr uint16, value uint8) {
+	d.value = value
+	d.source = uint16(value) << 8
+	d.index = 0
+	d.active = true
+}
+
+func (d *DMA) Tick() {
+	if !d.active {
+		return
+	}
+
+	// Sources above 0xDFFF read from echo RAM
+	source := d.source + uint16(d.index)
+	if source >= 0xE000 {
+		source -= 0x2000
+	}
+
+	d.ppu.OAM[d.index] = d.bus.Read(source)
+
+	d.index++
+	if d.index == len(d.ppu.OAM) {
+		d.active = false
+	}
+}
@@ -0,0 +1,31 @@
+package ppu
+
+import (
+	"testing"
+
+	"github.com/hmatheisen/gameboy/bus"
+)
+
+func TestDMA(t *testing.T) {
+	p := newTestPPU()
+	b := bus.NewMemoryBus()
+	b.Map(0xC000, 0xDFFF, bus.NewRAM(0xC000, 0x2000))
+	for i := uint16(0); i < 0xA0; i++ {
+		b.Write(0xC100+i, uint8(i))
+	}
+
+	dma := NewDMA(b, p)
+	dma.Write(0xFF46, 0xC1)
+	for i := 0; i < 0xA0; i++ {
+		dma.Tick()
+	}
+
+	for i := 0; i < 0xA0; i++ {
+		if p.OAM[i] != uint8(i) {
+			t.Fatalf("want: OAM[%d] = %02x; got %02x", i, i, p.OAM[i])
+		}
+	}
+	if dma.Read(0xFF46) != 0xC1 {
+		t.Errorf("want: DMA = c1; got %02x", dma.Read(0xFF46))
+	}
+}
@@ -0,0 +1,457 @@
+// Package ppu emulates the picture processing unit, which draws the 160x144
+// screen, and the OAM DMA feeding it sprites
+package ppu
+
+import (
+	"image"
+	"sort"
+
+	"github.com/hmatheisen/gameboy/cpu"
+	"github.com/hmatheisen/gameboy/interrupt"
+)
+
+const (
+	ScreenWidth  = 160
+	ScreenHeight = 144
+)
+
+// Timings in dots, there are 4 dots per machine cycle
+const (
+	dotsPerLine   = 456
+	linesPerFrame = 154
+	oamScanDots   = 80
+	drawingDots   = 172
+)
+
+// Machine cycles per frame, which makes for about 59.73 frames per second
+const (
+	CyclesPerFrame = dotsPerLine * linesPerFrame / 4
+	FrameRate      = float64(cpu.CyclesPerSecond) / CyclesPerFrame
+)
+
+type PPUMode uint8
+
+const (
+	ModeHBlank PPUMode = iota
+	ModeVBlank
+	ModeOAMScan
+	ModeDrawing
+)
+
+// LCDC bits
+const (
+	lcdcBGEnable     = 1 << 0
+	lcdcOBJEnable    = 1 << 1
+	lcdcOBJSize      = 1 << 2
+	lcdcBGTileMap    = 1 << 3
+	lcdcTileData     = 1 << 4
+	lcdcWindowEnable = 1 << 5
+	lcdcWindowMap    = 1 << 6
+	lcdcEnable       = 1 << 7
+)
+
+// STAT interrupt source bits
+const (
+	statHBlank  = 1 << 3
+	statVBlank  = 1 << 4
+	statOAMScan = 1 << 5
+	statLYC     = 1 << 6
+)
+
+// OAM attribute bits
+const (
+	objPalette  = 1 << 4
+	objFlipX    = 1 << 5
+	objFlipY    = 1 << 6
+	objPriority = 1 << 7
+)
+
+type sprite struct {
+	y, x  uint8
+	tile  uint8
+	flags uint8
+	index int
+}
+
+type PPU struct {
+	Renderer Renderer
+
+	VRAM [0x2000]uint8
+	OAM  [0xA0]uint8
+
+	LCDC, STAT uint8
+	SCY, SCX   uint8
+	LY, LYC    uint8
+	BGP        uint8
+	OBP0, OBP1 uint8
+	WY, WX     uint8
+
+	// Frame holds shades from 0 (white) to 3 (black), row by row
+	Frame [ScreenWidth * ScreenHeight]uint8
+	// Frames counts frames completed since power on
+	Frames int
+
+	mode PPUMode
+	dot  int
+
+	// The window has its own line counter, which only advances on lines
+	// where the window was actually drawn
+	windowLine int
+
+	// Interrupts are requested on the rising edge of the STAT line
+	statLine bool
+
+	sprites    []sprite
+	fifo       pixelFIFO
+	interrupts *interrupt.Interrupts
+}
+
+func New(interrupts *interrupt.Interrupts) *PPU {
+	ppu := new(PPU)
+
+	ppu.interrupts = interrupts
+	ppu.LCDC = 0x91
+	ppu.BGP = 0xFC
+	ppu.mode = ModeOAMScan
+	ppu.sprites = make([]sprite, 0, 10)
+
+	return ppu
+}
+
+func (p *PPU) Mode() PPUMode {
+	return p.mode
+}
+
+// Gray levels of the four shades, from white to black
+var shadeLevels = [4]uint8{0xFF, 0xAA, 0x55, 0x00}
+
+// Image returns the last frame as a grayscale image
+func (p *PPU) Image() *image.Gray {
+	img := image.NewGray(image.Rect(0, 0, ScreenWidth, ScreenHeight))
+	for i, shade := range p.Frame {
+		img.Pix[i] = shadeLevels[shade&0x03]
+	}
+	return img
+}
+
+// Tick advances the PPU by one machine cycle
+func (p *PPU) Tick() {
+	if p.LCDC&lcdcEnable == 0 {
+		return
+	}
+
+	for i := 0; i < 4; i++ {
+		p.step()
+	}
+}
+
+func (p *PPU) step() {
+	p.dot++
+	if p.dot == dotsPerLine {
+		p.dot = 0
+		p.LY++
+		if p.LY == linesPerFrame {
+			p.LY = 0
+			p.windowLine = 0
+		}
+	}
+
+	switch {
+	case p.LY >= ScreenHeight:
+		if p.LY == ScreenHeight && p.dot == 0 {
+			p.mode = ModeVBlank
+			p.Frames++
+			p.interrupts.Request(interrupt.VBlank)
+		}
+	case p.dot == 0:
+		p.mode = ModeOAMScan
+		if p.LY == 0 {
+			p.fifo.windowY = false
+		}
+		if p.LY == p.WY {
+			p.fifo.windowY = true
+		}
+	case p.dot == oamScanDots:
+		p.scanOAM()
+		p.mode = ModeDrawing
+		if p.Renderer == RendererFIFO {
+			p.startFIFO()
+		}
+	case p.mode == ModeDrawing && p.Renderer == RendererFIFO:
+		if p.stepFIFO() {
+			p.mode = ModeHBlank
+		}
+	case p.mode == ModeDrawing && p.dot == oamScanDots+drawingDots:
+		p.renderLine()
+		p.mode = ModeHBlank
+	}
+
+	p.updateSTAT()
+}
+
+func (p *PPU) updateSTAT() {
+	line := p.LY == p.LYC && p.STAT&statLYC != 0
+	switch p.mode {
+	case ModeHBlank:
+		line = line || p.STAT&statHBlank != 0
+	case ModeVBlank:
+		line = line || p.STAT&statVBlank != 0
+	case ModeOAMScan:
+		line = line || p.STAT&statOAMScan != 0
+	}
+
+	if line && !p.statLine {
+		p.interrupts.Request(interrupt.LCD)
+	}
+	p.statLine = line
+}
+
+func (p *PPU) setLCDC(value uint8) {
+	wasOn := p.LCDC&lcdcEnable != 0
+	p.LCDC = value
+
+	switch on := value&lcdcEnable != 0; {
+	case wasOn && !on:
+		p.LY = 0
+		p.dot = 0
+		p.mode = ModeHBlank
+		p.windowLine = 0
+	case !wasOn && on:
+		p.mode = ModeOAMScan
+		p.fifo.windowY = p.WY == 0
+		p.updateSTAT()
+	}
+}
+
+func (p *PPU) Read(addr uint16) uint8 {
+	switch {
+	case addr < 0xA000:
+		if p.mode == ModeDrawing {
+			return 0xFF
+		}
+		return p.VRAM[addr-0x8000]
+	case addr < 0xFF00:
+		if p.mode == ModeOAMScan || p.mode == ModeDrawing {
+			return 0xFF
+		}
+		return p.OAM[addr-0xFE00]
+	}
+
+	switch addr {
+	case 0xFF40:
+		return p.LCDC
+	case 0xFF41:
+		stat := 0x80 | p.STAT&0x78 | uint8(p.mode)
+		if p.LY == p.LYC {
+			stat |= 0x04
+		}
+		return stat
+	case 0xFF42:
+		return p.SCY
+	case 0xFF43:
+		return p.SCX
+	case 0xFF44:
+		return p.LY
+	case 0xFF45:
+		return p.LYC
+	case 0xFF47:
+		return p.BGP
+	case 0xFF48:
+		return p.OBP0
+	case 0xFF49:
+		return p.OBP1
+	case 0xFF4A:
+		return p.WY
+	case 0xFF4B:
+		return p.WX
+	}
+
+	return 0xFF
+}
+
+func (p *PPU) Write(addr uint16, value uint8) {
+	switch {
+	case addr < 0xA000:
+		if p.mode != ModeDrawing {
+			p.VRAM[addr-0x8000] = value
+		}
+		return
+	case addr < 0xFF00:
+		if p.mode != ModeOAMScan && p.mode != ModeDrawing {
+			p.OAM[addr-0xFE00] = value
+		}
+		return
+	}
+
+	switch addr {
+	case 0xFF40:
+		p.setLCDC(value)
+	case 0xFF41:
+		p.STAT = value & 0x78
+		p.updateSTAT()
+	case 0xFF42:
+		p.SCY = value
+	case 0xFF43:
+		p.SCX = value
+	case 0xFF45:
+		p.LYC = value
+		p.updateSTAT()
+	case 0xFF47:
+		p.BGP = value
+	case 0xFF48:
+		p.OBP0 = value
+	case 0xFF49:
+		p.OBP1 = value
+	case 0xFF4A:
+		p.WY = value
+	case 0xFF4B:
+		p.WX = value
+	}
+}
+
+func (p *PPU) spriteHeight() int {
+	if p.LCDC&lcdcOBJSize != 0 {
+		return 16
+	}
+	return 8
+}
+
+// Selects the first 10 sprites in OAM order that overlap the current line,
+// then orders them by drawing priority: lower X first, then OAM order
+func (p *PPU) scanOAM() {
+	p.sprites = p.sprites[:0]
+
+	height := p.spriteHeight()
+	for i := 0; i < 40 && len(p.sprites) < 10; i++ {
+		y := p.OAM[i*4]
+		top := int(y) - 16
+		if int(p.LY) < top || int(p.LY) >= top+height {
+			continue
+		}
+
+		p.sprites = append(p.sprites, sprite{
+			y:     y,
+			x:     p.OAM[i*4+1],
+			tile:  p.OAM[i*4+2],
+			flags: p.OAM[i*4+3],
+			index: i,
+		})
+	}
+
+	sort.SliceStable(p.sprites, func(i, j int) bool {
+		return p.sprites[i].x < p.sprites[j].x
+	})
+}
+
+// Returns the 2 bits color index of a pixel in a tile, addr being the tile
+// row in VRAM
+func (p *PPU) tilePixel(addr uint16, x int) uint8 {
+	lo := p.VRAM[addr-0x8000]
+	hi := p.VRAM[addr-0x8000+1]
+	bit := 7 - uint(x)
+
+	return (hi>>bit&1)<<1 | lo>>bit&1
+}
+
+// Background and window tiles are addressed from 0x8000 with an unsigned
+// index, or from 0x9000 with a signed one
+func (p *PPU) bgTileRow(index uint8, row int) uint16 {
+	if p.LCDC&lcdcTileData != 0 {
+		return 0x8000 + uint16(index)*16 + uint16(row)*2
+	}
+	return uint16(0x9000+int(int8(index))*16) + uint16(row)*2
+}
+
+func (p *PPU) tileMap(bit uint8) uint16 {
+	if p.LCDC&bit != 0 {
+		return 0x9C00
+	}
+	return 0x9800
+}
+
+func shade(palette, color uint8) uint8 {
+	return palette >> (color * 2) & 0x03
+}
+
+func (p *PPU) renderLine() {
+	var bg [ScreenWidth]uint8
+	line := p.Frame[int(p.LY)*ScreenWidth:][:ScreenWidth]
+
+	if p.LCDC&lcdcBGEnable != 0 {
+		y := int(p.LY+p.SCY) & 0xFF
+		tileMap := p.tileMap(lcdcBGTileMap)
+
+		for x := 0; x < ScreenWidth; x++ {
+			px := (x + int(p.SCX)) & 0xFF
+			index := p.VRAM[tileMap-0x8000+uint16(y/8*32+px/8)]
+			bg[x] = p.tilePixel(p.bgTileRow(index, y%8), px%8)
+		}
+
+		windowX := int(p.WX) - 7
+		if p.LCDC&lcdcWindowEnable != 0 && p.LY >= p.WY && windowX < ScreenWidth {
+			tileMap := p.tileMap(lcdcWindowMap)
+			y := p.windowLine
+
+			for x := max(windowX, 0); x < ScreenWidth; x++ {
+				px := x - windowX
+				index := p.VRAM[tileMap-0x8000+uint16(y/8*32+px/8)]
+				bg[x] = p.tilePixel(p.bgTileRow(index, y%8), px%8)
+			}
+			p.windowLine++
+		}
+	}
+
+	for x := 0; x < ScreenWidth; x++ {
+		line[x] = shade(p.BGP, bg[x])
+	}
+
+	if p.LCDC&lcdcOBJEnable == 0 {
+		return
+	}
+
+	var drawn [ScreenWidth]bool
+	height := p.spriteHeight()
+
+	for _, s := range p.sprites {
+		row := int(p.LY) - (int(s.y) - 16)
+		if s.flags&objFlipY != 0 {
+			row = height - 1 - row
+		}
+
+		tile := s.tile
+		if height == 16 {
+			tile &= 0xFE
+		}
+		addr := 0x8000 + uint16(tile)*16 + uint16(row)*2
+
+		palette := p.OBP0
+		if s.flags&objPalette != 0 {
+			palette = p.OBP1
+		}
+
+		for i := 0; i < 8; i++ {
+			x := int(s.x) - 8 + i
+			if x < 0 || x >= ScreenWidth || drawn[x] {
+				continue
+			}
+
+			col := i
+			if s.flags&objFlipX != 0 {
+				col = 7 - i
+			}
+
+			color := p.tilePixel(addr, col)
+			if color == 0 {
+				continue
+			}
+
+			// The highest priority opaque sprite pixel wins, even if it
+			// then ends up hidden behind the background
+			drawn[x] = true
+			if s.flags&objPriority != 0 && bg[x] != 0 {
+				continue
+			}
+			line[x] = shade(palette, color)
+		}
+	}
+}
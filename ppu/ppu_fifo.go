@@ -0,0 +1,301 @@
+package ppu
+
+// Renderer selects how the PPU turns VRAM into pixels
+type Renderer int
+
+const (
+	// RendererScanline draws a whole line at the end of the drawing mode
+	RendererScanline Renderer = iota
+	// RendererFIFO shifts pixels out one dot at a time through a background
+	// and a sprite FIFO, so registers written in the middle of a line take
+	// effect where they would on hardware and the drawing mode length varies
+	// with scrolling, the window and sprites
+	RendererFIFO
+)
+
+// Dots spent at the start of the drawing mode before the fetcher starts,
+// this is where the first tile fetch, which hardware throws away, goes
+const fifoStartDelay = 6
+
+type fifoPixel struct {
+	color    uint8
+	palette  uint8
+	priority bool
+}
+
+type pixelQueue struct {
+	pixels [16]fifoPixel
+	head   int
+	size   int
+}
+
+func (q *pixelQueue) push(p fifoPixel) {
+	q.pixels[(q.head+q.size)%len(q.pixels)] = p
+	q.size++
+}
+
+func (q *pixelQueue) pop() fifoPixel {
+	p := q.pixels[q.head]
+	q.head = (q.head + 1) % len(q.pixels)
+	q.size--
+	return p
+}
+
+func (q *pixelQueue) at(i int) *fifoPixel {
+	return &q.pixels[(q.head+i)%len(q.pixels)]
+}
+
+func (q *pixelQueue) clear() {
+	q.head = 0
+	q.size = 0
+}
+
+type pixelFIFO struct {
+	bg  pixelQueue
+	obj pixelQueue
+
+	// The background fetcher goes through 4 steps: read the tile number,
+	// the low then high byte of the tile row, each taking 2 dots, and push
+	// 8 pixels once the background FIFO is empty
+	fetchStep      int
+	fetchDots      int
+	fetchX         int
+	tileIndex      uint8
+	tileLo         uint8
+	tileHi         uint8
+	fetchingWindow bool
+
+	// Pixels sent to the LCD on this line, and pixels still to throw away
+	// for fine horizontal scrolling
+	lx      int
+	discard int
+	delay   int
+
+	// Sprites are fetched in the order picked by the OAM scan, the
+	// background fetcher first completes its current tile
+	nextSprite     int
+	fetchingSprite bool
+	spriteDots     int
+
+	// The window shows up once LY matched WY during the frame
+	windowY     bool
+	windowDrawn bool
+}
+
+func (p *PPU) startFIFO() {
+	f := &p.fifo
+
+	f.bg.clear()
+	f.obj.clear()
+	f.fetchStep = 0
+	f.fetchDots = 0
+	f.fetchX = 0
+	f.fetchingWindow = false
+	f.lx = 0
+	f.discard = int(p.SCX & 7)
+	f.delay = fifoStartDelay
+	f.nextSprite = 0
+	f.fetchingSprite = false
+	f.windowDrawn = false
+}
+
+// Advances the drawing mode by one dot, returning true once the 160 pixels
+// of the line are out
+func (p *PPU) stepFIFO() bool {
+	f := &p.fifo
+
+	if f.delay > 0 {
+		f.delay--
+		return false
+	}
+
+	if f.fetchingSprite {
+		if !p.fetcherReady() {
+			p.stepFetcher()
+			return false
+		}
+
+		// Along with the dot spent noticing the sprite, this makes fetching
+		// a sprite cost 6 dots on top of waiting for the fetcher
+		f.spriteDots++
+		if f.spriteDots == 5 {
+			p.fetchSprite(p.sprites[f.nextSprite])
+			f.nextSprite++
+			f.fetchingSprite = false
+		}
+		return false
+	}
+
+	p.stepFetcher()
+
+	if f.bg.size == 0 {
+		return false
+	}
+
+	for f.nextSprite < len(p.sprites) && int(p.sprites[f.nextSprite].x) <= f.lx+8 {
+		if p.LCDC&lcdcOBJEnable != 0 {
+			f.fetchingSprite = true
+			f.spriteDots = 0
+			return false
+		}
+		f.nextSprite++
+	}
+
+	if !f.fetchingWindow && p.windowActive() && f.lx+7 >= int(p.WX) {
+		f.fetchingWindow = true
+		f.windowDrawn = true
+		f.bg.clear()
+		f.fetchStep = 0
+		// The restarted fetch overlaps the dot spent on the trigger, so
+		// the window costs 6 dots
+		f.fetchDots = 1
+		f.fetchX = 0
+		if f.lx == 0 && p.WX < 7 {
+			f.discard = 7 - int(p.WX)
+		}
+		return false
+	}
+
+	bg := f.bg.pop()
+	if f.discard > 0 {
+		f.discard--
+		return false
+	}
+
+	var obj fifoPixel
+	if f.obj.size > 0 {
+		obj = f.obj.pop()
+	}
+
+	p.Frame[int(p.LY)*ScreenWidth+f.lx] = p.mixPixel(bg, obj)
+	f.lx++
+
+	if f.lx < ScreenWidth {
+		return false
+	}
+
+	if f.windowDrawn {
+		p.windowLine++
+	}
+	return true
+}
+
+func (p *PPU) windowActive() bool {
+	return p.LCDC&lcdcWindowEnable != 0 && p.fifo.windowY && p.WX <= 166
+}
+
+// Sprite fetches can start during the last dot of the background fetch
+func (p *PPU) fetcherReady() bool {
+	f := &p.fifo
+	return f.fetchStep == 3 || f.fetchStep == 2 && f.fetchDots == 1
+}
+
+func (p *PPU) stepFetcher() {
+	f := &p.fifo
+
+	if f.fetchStep == 3 {
+		if f.bg.size == 0 {
+			for x := 0; x < 8; x++ {
+				bit := 7 - uint(x)
+				f.bg.push(fifoPixel{color: (f.tileHi>>bit&1)<<1 | f.tileLo>>bit&1})
+			}
+			f.fetchX++
+			f.fetchStep = 0
+			f.fetchDots = 0
+		}
+		return
+	}
+
+	f.fetchDots++
+	if f.fetchDots < 2 {
+		return
+	}
+	f.fetchDots = 0
+
+	var y int
+	if f.fetchingWindow {
+		y = p.windowLine
+	} else {
+		y = int(p.LY+p.SCY) & 0xFF
+	}
+
+	switch f.fetchStep {
+	case 0:
+		var addr uint16
+		if f.fetchingWindow {
+			addr = p.tileMap(lcdcWindowMap) + uint16(y/8*32+f.fetchX&0x1F)
+		} else {
+			x := (int(p.SCX)/8 + f.fetchX) & 0x1F
+			addr = p.tileMap(lcdcBGTileMap) + uint16(y/8*32+x)
+		}
+		f.tileIndex = p.VRAM[addr-0x8000]
+	case 1:
+		f.tileLo = p.VRAM[p.bgTileRow(f.tileIndex, y%8)-0x8000]
+	case 2:
+		f.tileHi = p.VRAM[p.bgTileRow(f.tileIndex, y%8)-0x8000+1]
+	}
+
+	f.fetchStep++
+}
+
+// Merges a sprite row in the sprite FIFO, pixels already there win since
+// they come from sprites with a higher priority
+func (p *PPU) fetchSprite(s sprite) {
+	f := &p.fifo
+
+	height := p.spriteHeight()
+	row := int(p.LY) - (int(s.y) - 16)
+	if s.flags&objFlipY != 0 {
+		row = height - 1 - row
+	}
+
+	tile := s.tile
+	if height == 16 {
+		tile &= 0xFE
+	}
+	addr := 0x8000 + uint16(tile)*16 + uint16(row)*2
+
+	for i := 0; i < 8; i++ {
+		x := int(s.x) - 8 + i
+		if x < f.lx {
+			continue
+		}
+
+		col := i
+		if s.flags&objFlipX != 0 {
+			col = 7 - i
+		}
+
+		pixel := fifoPixel{
+			color:    p.tilePixel(addr, col),
+			palette:  s.flags & objPalette >> 4,
+			priority: s.flags&objPriority != 0,
+		}
+
+		j := x - f.lx
+		for f.obj.size <= j {
+			f.obj.push(fifoPixel{})
+		}
+		if f.obj.at(j).color == 0 {
+			*f.obj.at(j) = pixel
+		}
+	}
+}
+
+// Palettes are applied as pixels leave the FIFOs
+func (p *PPU) mixPixel(bg, obj fifoPixel) uint8 {
+	color := bg.color
+	if p.LCDC&lcdcBGEnable == 0 {
+		color = 0
+	}
+
+	if obj.color != 0 && p.LCDC&lcdcOBJEnable != 0 && !(obj.priority && color != 0) {
+		palette := p.OBP0
+		if obj.palette != 0 {
+			palette = p.OBP1
+		}
+		return shade(palette, obj.color)
+	}
+
+	return shade(p.BGP, color)
+}
@@ -0,0 +1,134 @@
+package ppu
+
+import "testing"
+
+// Runs the PPU until the given line is drawn and returns how long it took
+func drawingDotsOn(p *PPU, line uint8) int {
+	for p.LY != line || p.Mode() != ModeDrawing {
+		p.step()
+	}
+
+	dots := 0
+	for p.Mode() == ModeDrawing {
+		p.step()
+		dots++
+	}
+	return dots
+}
+
+func TestFIFOMatchesScanline(t *testing.T) {
+	scenes := []struct {
+		name  string
+		lcdc  uint8
+		setup func(p *PPU)
+	}{
+		{"scrolled background", 0x91, func(p *PPU) {
+			p.SCX = 13
+			p.SCY = 5
+		}},
+		{"window", 0xF1, func(p *PPU) {
+			p.SCX = 3
+			p.WX = 7 + 50
+			p.WY = 20
+		}},
+		{"window at the left edge", 0xF1, func(p *PPU) {
+			p.WX = 3
+			p.WY = 0
+		}},
+		{"sprites", 0x93, func(p *PPU) {
+			p.SCX = 5
+			setSprite(p, 0, 4, 16, 1, 0)
+			setSprite(p, 1, 20, 20, 2, objFlipX|objPalette)
+			setSprite(p, 2, 24, 24, 3, objPriority)
+			setSprite(p, 3, 164, 30, 1, objFlipY)
+		}},
+	}
+
+	for _, tt := range scenes {
+		var frames [2][ScreenWidth * ScreenHeight]uint8
+		for i, renderer := range []Renderer{RendererScanline, RendererFIFO} {
+			p := newTestPPU()
+			p.Renderer = renderer
+			p.BGP = 0xE4
+			p.OBP0 = 0xE4
+			p.OBP1 = 0x1B
+			// Every tile gets a different pattern to catch misplaced pixels
+			for i := range p.VRAM[:0x1800] {
+				p.VRAM[i] = uint8(i * 7)
+			}
+			for i := 0; i < 0x800; i++ {
+				p.VRAM[0x1800+i] = uint8(i % 5)
+			}
+			tt.setup(p)
+
+			p.setLCDC(tt.lcdc)
+			runPPUFrame(p)
+			runPPUFrame(p)
+			frames[i] = p.Frame
+		}
+
+		for i := range frames[0] {
+			if frames[0][i] != frames[1][i] {
+				t.Errorf("%s: (%d, %d): want: shade %d; got shade %d", tt.name, i%ScreenWidth, i/ScreenWidth, frames[0][i], frames[1][i])
+				break
+			}
+		}
+	}
+}
+
+func TestFIFODrawingLength(t *testing.T) {
+	tests := []struct {
+		name  string
+		lcdc  uint8
+		setup func(p *PPU)
+		dots  int
+	}{
+		{"plain", 0x91, func(p *PPU) {}, 172},
+		{"SCX fine scroll", 0x91, func(p *PPU) { p.SCX = 3 }, 175},
+		{"SCX coarse scroll", 0x91, func(p *PPU) { p.SCX = 8 }, 172},
+		{"sprite on a tile boundary", 0x93, func(p *PPU) { setSprite(p, 0, 24, 16, 0, 0) }, 183},
+		{"sprite inside a tile", 0x93, func(p *PPU) { setSprite(p, 0, 27, 16, 0, 0) }, 180},
+		{"sprite late in a tile", 0x93, func(p *PPU) { setSprite(p, 0, 30, 16, 0, 0) }, 178},
+		{"sprite at X 0", 0x93, func(p *PPU) { setSprite(p, 0, 0, 16, 0, 0) }, 183},
+		{"sprites disabled", 0x91, func(p *PPU) { setSprite(p, 0, 24, 16, 0, 0) }, 172},
+		{"window", 0xB1, func(p *PPU) { p.WX = 7 + 40 }, 178},
+		{"window off screen", 0xB1, func(p *PPU) { p.WX = 167 }, 172},
+	}
+
+	for _, tt := range tests {
+		p := newTestPPU()
+		p.Renderer = RendererFIFO
+		tt.setup(p)
+		p.setLCDC(tt.lcdc)
+
+		if got := drawingDotsOn(p, 1); got != tt.dots {
+			t.Errorf("%s: want: %d dots; got %d", tt.name, tt.dots, got)
+		}
+	}
+}
+
+func TestFIFOMidLineWrite(t *testing.T) {
+	p := newTestPPU()
+	p.Renderer = RendererFIFO
+	p.BGP = 0xE4
+	setSolidTile(p, 0x8000, 1)
+	p.setLCDC(0x91)
+
+	for p.LY != 10 || p.Mode() != ModeDrawing {
+		p.step()
+	}
+	for p.fifo.lx < 80 {
+		p.step()
+	}
+	p.Write(0xFF47, 0xE0)
+	for p.Mode() == ModeDrawing {
+		p.step()
+	}
+
+	if got := pixel(p, 79, 10); got != 1 {
+		t.Errorf("before the write: want: shade 1; got shade %d", got)
+	}
+	if got := pixel(p, 80, 10); got != 0 {
+		t.Errorf("after the write: want: shade 0; got shade %d", got)
+	}
+}
@@ -0,0 +1,347 @@
+package ppu
+
+import (
+	"testing"
+
+	"github.com/hmatheisen/gameboy/interrupt"
+)
+
+func newTestPPU() *PPU {
+	ppu := New(interrupt.New())
+	ppu.interrupts.Flag = 0x00
+	ppu.setLCDC(0x00)
+
+	return ppu
+}
+
+func runPPUFrame(p *PPU) {
+	frames := p.Frames
+	for p.Frames == frames {
+		p.Tick()
+	}
+}
+
+// Writes a tile where every row uses the given 2 bits color
+func setSolidTile(p *PPU, addr uint16, color uint8) {
+	for row := uint16(0); row < 8; row++ {
+		p.VRAM[addr-0x8000+row*2] = 0xFF * (color & 1)
+		p.VRAM[addr-0x8000+row*2+1] = 0xFF * (color >> 1)
+	}
+}
+
+func pixel(p *PPU, x, y int) uint8 {
+	return p.Frame[y*ScreenWidth+x]
+}
+
+func TestPPUModeTiming(t *testing.T) {
+	p := newTestPPU()
+	p.setLCDC(0x91)
+
+	tests := []struct {
+		cycles int
+		ly     uint8
+		mode   PPUMode
+	}{
+		{19, 0, ModeOAMScan},
+		{1, 0, ModeDrawing},
+		{42, 0, ModeDrawing},
+		{1, 0, ModeHBlank},
+		{51, 1, ModeOAMScan},
+		{114*143 - 1, 143, ModeHBlank},
+		{1, 144, ModeVBlank},
+		{114 * 9, 153, ModeVBlank},
+		{114, 0, ModeOAMScan},
+	}
+
+	for i, tt := range tests {
+		for c := 0; c < tt.cycles; c++ {
+			p.Tick()
+		}
+		if p.LY != tt.ly || p.Mode() != tt.mode {
+			t.Errorf("step %d: want: LY = %d, mode %d; got LY = %d, mode %d", i, tt.ly, tt.mode, p.LY, p.Mode())
+		}
+	}
+
+	if p.Frames != 1 {
+		t.Errorf("want: 1 frame; got %d", p.Frames)
+	}
+	if p.interrupts.Flag&uint8(interrupt.VBlank) == 0 {
+		t.Errorf("want: VBlank interrupt requested")
+	}
+}
+
+func TestPPUFrameLength(t *testing.T) {
+	p := newTestPPU()
+	p.setLCDC(0x91)
+	runPPUFrame(p)
+
+	cycles := 0
+	frames := p.Frames
+	for p.Frames == frames {
+		p.Tick()
+		cycles++
+	}
+
+	if cycles != 17556 {
+		t.Errorf("want: 17556 cycles per frame; got %d", cycles)
+	}
+}
+
+func TestPPULCDOff(t *testing.T) {
+	p := newTestPPU()
+	p.setLCDC(0x91)
+	for i := 0; i < 1000; i++ {
+		p.Tick()
+	}
+
+	p.Write(0xFF40, 0x11)
+	p.Tick()
+
+	if p.LY != 0 || p.Mode() != ModeHBlank {
+		t.Errorf("want: LY = 0, mode 0; got LY = %d, mode %d", p.LY, p.Mode())
+	}
+	if p.Read(0xFF41)&0x03 != 0 {
+		t.Errorf("want: STAT mode 0; got %02x", p.Read(0xFF41))
+	}
+}
+
+func TestPPUSTATInterrupt(t *testing.T) {
+	p := newTestPPU()
+	p.Write(0xFF45, 2)
+	p.Write(0xFF41, statLYC)
+	p.setLCDC(0x91)
+	p.interrupts.Flag = 0
+
+	for p.LY != 2 {
+		p.Tick()
+	}
+
+	if p.interrupts.Flag&uint8(interrupt.LCD) == 0 {
+		t.Errorf("want: LCD interrupt on LY = LYC")
+	}
+	if p.Read(0xFF41)&0x04 == 0 {
+		t.Errorf("want: coincidence flag set; got STAT = %02x", p.Read(0xFF41))
+	}
+
+	// The line stays high for the rest of the line, no new interrupt
+	p.interrupts.Flag = 0
+	p.Write(0xFF41, statLYC|statHBlank)
+	for p.LY == 2 {
+		p.Tick()
+	}
+	if p.interrupts.Flag&uint8(interrupt.LCD) != 0 {
+		t.Errorf("want: no interrupt while the STAT line stays high")
+	}
+
+	for p.Mode() != ModeHBlank {
+		p.Tick()
+	}
+	if p.interrupts.Flag&uint8(interrupt.LCD) == 0 {
+		t.Errorf("want: LCD interrupt on HBlank")
+	}
+}
+
+func TestPPUAccessBlocking(t *testing.T) {
+	p := newTestPPU()
+	p.VRAM[0] = 0x12
+	p.OAM[0] = 0x34
+	p.setLCDC(0x91)
+
+	for p.Mode() != ModeOAMScan {
+		p.Tick()
+	}
+	if p.Read(0xFE00) != 0xFF || p.Read(0x8000) != 0x12 {
+		t.Errorf("OAM scan: want: OAM blocked; got OAM = %02x, VRAM = %02x", p.Read(0xFE00), p.Read(0x8000))
+	}
+
+	for p.Mode() != ModeDrawing {
+		p.Tick()
+	}
+	p.Write(0x8000, 0x00)
+	if p.Read(0x8000) != 0xFF || p.VRAM[0] != 0x12 {
+		t.Errorf("drawing: want: VRAM blocked; got %02x", p.VRAM[0])
+	}
+
+	for p.Mode() != ModeHBlank {
+		p.Tick()
+	}
+	if p.Read(0xFE00) != 0x34 || p.Read(0x8000) != 0x12 {
+		t.Errorf("HBlank: want: free access; got OAM = %02x, VRAM = %02x", p.Read(0xFE00), p.Read(0x8000))
+	}
+}
+
+func TestPPUBackground(t *testing.T) {
+	p := newTestPPU()
+	p.BGP = 0xE4
+	setSolidTile(p, 0x8010, 3)
+	// Row 0 of tile 2 alternates colors 1 and 2
+	p.VRAM[0x20] = 0xAA
+	p.VRAM[0x21] = 0x55
+	p.VRAM[0x1800] = 1
+	p.VRAM[0x1801] = 2
+	p.SCX = 4
+
+	p.setLCDC(0x91)
+	runPPUFrame(p)
+
+	tests := []struct {
+		x, y  int
+		shade uint8
+	}{
+		{0, 0, 3},
+		{3, 0, 3},
+		{4, 0, 1},
+		{5, 0, 2},
+		{4, 1, 0},
+		{12, 0, 0},
+	}
+	for _, tt := range tests {
+		if got := pixel(p, tt.x, tt.y); got != tt.shade {
+			t.Errorf("(%d, %d): want: shade %d; got shade %d", tt.x, tt.y, tt.shade, got)
+		}
+	}
+}
+
+func TestPPUSignedTileData(t *testing.T) {
+	p := newTestPPU()
+	p.BGP = 0xE4
+	setSolidTile(p, 0x8FF0, 2)
+	p.VRAM[0x1800] = 0xFF
+
+	p.setLCDC(0x81)
+	runPPUFrame(p)
+
+	if got := pixel(p, 0, 0); got != 2 {
+		t.Errorf("want: tile -1 at 0x8FF0; got shade %d", got)
+	}
+}
+
+func TestPPUWindow(t *testing.T) {
+	p := newTestPPU()
+	p.BGP = 0xE4
+	setSolidTile(p, 0x8010, 1)
+	setSolidTile(p, 0x8020, 2)
+	for i := 0; i < 0x400; i++ {
+		p.VRAM[0x1800+i] = 1
+		p.VRAM[0x1C00+i] = 2
+	}
+	p.WX = 7 + 40
+	p.WY = 100
+
+	p.setLCDC(0xF1)
+	runPPUFrame(p)
+
+	if got := pixel(p, 39, 100); got != 1 {
+		t.Errorf("left of window: want: shade 1; got shade %d", got)
+	}
+	if got := pixel(p, 40, 100); got != 2 {
+		t.Errorf("window: want: shade 2; got shade %d", got)
+	}
+	if got := pixel(p, 40, 99); got != 1 {
+		t.Errorf("above window: want: shade 1; got shade %d", got)
+	}
+}
+
+func setSprite(p *PPU, index int, x, y, tile, flags uint8) {
+	p.OAM[index*4] = y
+	p.OAM[index*4+1] = x
+	p.OAM[index*4+2] = tile
+	p.OAM[index*4+3] = flags
+}
+
+func TestPPUSprites(t *testing.T) {
+	p := newTestPPU()
+	p.BGP = 0xE4
+	p.OBP0 = 0xE4
+	p.OBP1 = 0x40
+	setSolidTile(p, 0x8010, 2)
+	setSolidTile(p, 0x8020, 3)
+	// Tile 3 only has its leftmost column set
+	for row := 0; row < 8; row++ {
+		p.VRAM[0x30+row*2] = 0x80
+		p.VRAM[0x30+row*2+1] = 0x80
+	}
+
+	// Sprite at the lower X wins, transparent pixels let the other through
+	setSprite(p, 0, 20, 16, 1, 0)
+	setSprite(p, 1, 16, 16, 3, 0)
+	// OBP1 and horizontal flip
+	setSprite(p, 2, 48, 16, 3, objPalette|objFlipX)
+	// Behind a non zero background
+	setSprite(p, 3, 80, 16, 2, objPriority)
+	p.VRAM[0x1800+9] = 1
+
+	p.setLCDC(0x93)
+	runPPUFrame(p)
+
+	tests := []struct {
+		name  string
+		x     int
+		shade uint8
+	}{
+		{"sprite 1 opaque pixel", 8, 3},
+		{"sprite 0 through sprite 1", 12, 2},
+		{"flipped with OBP1", 47, 1},
+		{"flipped transparent", 40, 0},
+		{"hidden behind background", 72, 2},
+	}
+	for _, tt := range tests {
+		if got := pixel(p, tt.x, 0); got != tt.shade {
+			t.Errorf("%s: want: shade %d; got shade %d", tt.name, tt.shade, got)
+		}
+	}
+}
+
+func TestPPUTenSpritesPerLine(t *testing.T) {
+	p := newTestPPU()
+	p.OBP0 = 0xE4
+	setSolidTile(p, 0x8010, 3)
+	for i := 0; i < 12; i++ {
+		setSprite(p, i, uint8(8+i*8), 16, 1, 0)
+	}
+
+	p.setLCDC(0x93)
+	runPPUFrame(p)
+
+	if got := pixel(p, 9*8, 0); got != 3 {
+		t.Errorf("10th sprite: want: drawn; got shade %d", got)
+	}
+	if got := pixel(p, 10*8, 0); got != 0 {
+		t.Errorf("11th sprite: want: dropped; got shade %d", got)
+	}
+}
+
+func TestPPUTallSprites(t *testing.T) {
+	p := newTestPPU()
+	p.OBP0 = 0xE4
+	setSolidTile(p, 0x8020, 1)
+	setSolidTile(p, 0x8030, 2)
+	setSprite(p, 0, 8, 16, 3, objFlipY)
+
+	p.setLCDC(0x97)
+	runPPUFrame(p)
+
+	if got := pixel(p, 0, 0); got != 2 {
+		t.Errorf("top half: want: shade 2; got shade %d", got)
+	}
+	if got := pixel(p, 0, 8); got != 1 {
+		t.Errorf("bottom half: want: shade 1; got shade %d", got)
+	}
+}
+
+func TestImage(t *testing.T) {
+	p := newTestPPU()
+	for i := range p.Frame {
+		p.Frame[i] = uint8(i % 4)
+	}
+
+	img := p.Image()
+	for x, want := range []uint8{0xFF, 0xAA, 0x55, 0x00} {
+		if got := img.GrayAt(x, 0).Y; got != want {
+			t.Errorf("shade %d: want: gray %02x; got %02x", x, want, got)
+		}
+	}
+	if got := img.GrayAt(ScreenWidth-1, ScreenHeight-1).Y; got != 0x00 {
+		t.Errorf("bottom right: want: gray 00; got %02x", got)
+	}
+}
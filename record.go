@@ -0,0 +1,90 @@
+package gameboy
+
+import (
+	"errors"
+	"math"
+
+	"github.com/hmatheisen/gameboy/wav"
+)
+
+// Samples quieter than this count as silence
+const silenceThreshold = 1.0 / 1024
+
+// RecordOptions tells RecordAudio when to stop, at least one limit must be
+// set
+type RecordOptions struct {
+	// Frames stops the recording after this many frames
+	Frames int
+	// SilenceFrames stops the recording once the output stayed silent for
+	// this many frames
+	SilenceFrames int
+	// Until stops the recording once it returns true, it is called after
+	// every frame
+	Until func(gb *Emulator) bool
+
+	// Stems receive each channel on its own when set
+	Stems [4]*wav.Writer
+}
+
+// RecordAudio runs the emulator frame by frame, writing the APU output to
+// the WAV writer, and returns the number of frames recorded
+func RecordAudio(gb *Emulator, w *wav.Writer, options RecordOptions) (int, error) {
+	if options.Frames <= 0 && options.SilenceFrames <= 0 && options.Until == nil {
+		return 0, errors.New("record: no limit set")
+	}
+
+	stems := options.Stems != [4]*wav.Writer{}
+	if stems {
+		gb.APU.EnableStems(true)
+		defer gb.APU.EnableStems(false)
+	}
+
+	// Drop anything produced before the recording started
+	gb.APU.DrainSamples()
+
+	silent := 0
+	for frame := 1; ; frame++ {
+		// Frames are counted in cycles rather than VBlanks, so recording
+		// goes on while the LCD is off
+		if err := gb.RunFor(CyclesPerFrame); err != nil {
+			return frame, err
+		}
+
+		samples := gb.APU.DrainSamples()
+		if err := w.WriteSamples(samples); err != nil {
+			return frame, err
+		}
+		if stems {
+			for i, samples := range gb.APU.DrainStems() {
+				if options.Stems[i] == nil {
+					continue
+				}
+				if err := options.Stems[i].WriteSamples(samples); err != nil {
+					return frame, err
+				}
+			}
+		}
+
+		if isSilent(samples) {
+			silent++
+		} else {
+			silent = 0
+		}
+
+		switch {
+		case options.Frames > 0 && frame >= options.Frames,
+			options.SilenceFrames > 0 && silent >= options.SilenceFrames,
+			options.Until != nil && options.Until(gb):
+			return frame, nil
+		}
+	}
+}
+
+func isSilent(samples []float32) bool {
+	for _, sample := range samples {
+		if math.Abs(float64(sample)) >= silenceThreshold {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,128 @@
+package gameboy
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hmatheisen/gameboy/apu"
+	"github.com/hmatheisen/gameboy/cpu"
+	"github.com/hmatheisen/gameboy/wav"
+)
+
+// Sets up an Emulator idling in HALT while square channel 2 plays a note
+func newTestRecording(t *testing.T) (*Emulator, *wav.Writer, *os.File) {
+	gb := New(WithSampleRate(8000))
+	gb.CPU.PC = 0xC000
+	gb.Interrupts.Flag = 0x00
+	loadProgram(gb, 0xC000, 0x76)
+
+	gb.Bus.Write(0xFF25, 0x22)
+	gb.Bus.Write(0xFF17, 0xF0)
+	gb.Bus.Write(0xFF19, 0x87)
+
+	file, err := os.Create(filepath.Join(t.TempDir(), "out.wav"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { file.Close() })
+
+	w, err := wav.NewWriter(file, 8000, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return gb, w, file
+}
+
+func TestRecordFrames(t *testing.T) {
+	gb, w, file := newTestRecording(t)
+
+	frames, err := RecordAudio(gb, w, RecordOptions{Frames: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if frames != 3 {
+		t.Errorf("want: 3 frames; got %d", frames)
+	}
+	if gb.MCycles < 3*CyclesPerFrame {
+		t.Errorf("want: at least %d cycles; got %d", 3*CyclesPerFrame, gb.MCycles)
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	info, _ := file.Stat()
+	// 3 frames of stereo 16-bit samples at 8 kHz
+	samples := 3 * CyclesPerFrame * 4 * 8000 / (4 * cpu.CyclesPerSecond)
+	if got := int(info.Size()) - wav.HeaderSize; got != samples*4 {
+		t.Errorf("want: %d bytes of samples; got %d", samples*4, got)
+	}
+}
+
+func TestRecordUntilSilence(t *testing.T) {
+	gb, w, _ := newTestRecording(t)
+	// The note stops after 1/4 of a second
+	gb.Bus.Write(0xFF16, 0x00)
+	gb.Bus.Write(0xFF19, 0xC7)
+
+	frames, err := RecordAudio(gb, w, RecordOptions{Frames: 600, SilenceFrames: 30})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if frames < 30 || frames > 30+30 {
+		t.Errorf("want: about 45 frames; got %d", frames)
+	}
+}
+
+func TestRecordUntil(t *testing.T) {
+	gb, w, _ := newTestRecording(t)
+
+	frames, err := RecordAudio(gb, w, RecordOptions{Until: func(gb *Emulator) bool {
+		return gb.MCycles >= 10*CyclesPerFrame
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if frames != 10 {
+		t.Errorf("want: 10 frames; got %d", frames)
+	}
+
+	if _, err := RecordAudio(gb, w, RecordOptions{}); err == nil {
+		t.Errorf("want: error without any limit")
+	}
+}
+
+func TestRecordStems(t *testing.T) {
+	gb, w, _ := newTestRecording(t)
+
+	dir := t.TempDir()
+	var stems [4]*wav.File
+	for i := range stems {
+		stem, err := wav.Create(filepath.Join(dir, apu.Channel(i).String()+".wav"), 8000, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		stems[i] = stem
+	}
+
+	options := RecordOptions{Frames: 2}
+	for i, stem := range stems {
+		options.Stems[i] = stem.Writer
+	}
+	if _, err := RecordAudio(gb, w, options); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, stem := range stems {
+		if err := stem.Close(); err != nil {
+			t.Fatal(err)
+		}
+		info, err := os.Stat(filepath.Join(dir, apu.Channel(i).String()+".wav"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Size() != int64(wav.HeaderSize+w.DataSize()) {
+			t.Errorf("%s: want: %d bytes; got %d", apu.Channel(i), wav.HeaderSize+w.DataSize(), info.Size())
+		}
+	}
+}
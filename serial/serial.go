@@ -0,0 +1,84 @@
+// Package serial emulates the link port with nothing plugged in
+package serial
+
+import (
+	"io"
+
+	"github.com/hmatheisen/gameboy/cpu"
+	"github.com/hmatheisen/gameboy/interrupt"
+)
+
+// Machine cycles per bit with the internal 8192 Hz clock
+const serialBitCycles = cpu.CyclesPerSecond / 8192
+
+// Serial is the link port, SB (0xFF01) and SC (0xFF02). Nothing is plugged
+// in, so transfers clocked by the Game Boy shift in 1s, and transfers waiting
+// for an external clock never end
+type Serial struct {
+	SB uint8
+	SC uint8
+
+	// Out receives every byte sent, test ROMs print their results this way
+	Out io.Writer
+
+	bits  int
+	timer int
+
+	interrupts *interrupt.Interrupts
+}
+
+func New(interrupts *interrupt.Interrupts) *Serial {
+	serial := new(Serial)
+
+	serial.interrupts = interrupts
+	serial.SC = 0x7E
+
+	return serial
+}
+
+func (s *Serial) transferring() bool {
+	return s.SC&0x81 == 0x81
+}
+
+// Tick advances the serial port by one machine cycle
+func (s *Serial) Tick() {
+	if !s.transferring() {
+		return
+	}
+
+	s.timer++
+	if s.timer < serialBitCycles {
+		return
+	}
+	s.timer = 0
+
+	s.SB = s.SB<<1 | 1
+	s.bits++
+	if s.bits == 8 {
+		s.SC &^= 0x80
+		s.interrupts.Request(interrupt.Serial)
+	}
+}
+
+func (s *Serial) Read(addr uint16) uint8 {
+	if addr == 0xFF01 {
+		return s.SB
+	}
+	return s.SC | 0x7E
+}
+
+func (s *Serial) Write(addr uint16, value uint8) {
+	if addr == 0xFF01 {
+		s.SB = value
+		return
+	}
+
+	s.SC = value | 0x7E
+	if s.transferring() {
+		s.bits = 0
+		s.timer = 0
+		if s.Out != nil {
+			s.Out.Write([]byte{s.SB})
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package serial
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/hmatheisen/gameboy/interrupt"
+)
+
+func TestSerialTransfer(t *testing.T) {
+	var out bytes.Buffer
+	s := New(interrupt.New())
+	s.interrupts.Flag = 0x00
+	s.Out = &out
+
+	s.Write(0xFF01, 'A')
+	s.Write(0xFF02, 0x81)
+	if out.String() != "A" {
+		t.Errorf("want: A sent; got %q", out.String())
+	}
+
+	for i := 0; i < 8*serialBitCycles-1; i++ {
+		s.Tick()
+	}
+	if s.Read(0xFF02) != 0xFF || s.interrupts.Flag != 0 {
+		t.Fatalf("want: transfer running; got SC = %02x, IF = %02x", s.Read(0xFF02), s.interrupts.Flag)
+	}
+
+	s.Tick()
+	if s.Read(0xFF02) != 0x7F {
+		t.Errorf("want: SC = 7f; got %02x", s.Read(0xFF02))
+	}
+	if s.interrupts.Flag != uint8(interrupt.Serial) {
+		t.Errorf("want: serial interrupt; got IF = %02x", s.interrupts.Flag)
+	}
+	// Nothing connected, only 1s come in
+	if s.Read(0xFF01) != 0xFF {
+		t.Errorf("want: SB = ff; got %02x", s.Read(0xFF01))
+	}
+}
+
+func TestSerialExternalClock(t *testing.T) {
+	s := New(interrupt.New())
+	s.interrupts.Flag = 0x00
+
+	s.Write(0xFF01, 0x12)
+	s.Write(0xFF02, 0x80)
+	for i := 0; i < 100*serialBitCycles; i++ {
+		s.Tick()
+	}
+
+	if s.Read(0xFF02) != 0xFE || s.Read(0xFF01) != 0x12 || s.interrupts.Flag != 0 {
+		t.Errorf("want: transfer waiting; got SC = %02x, SB = %02x, IF = %02x", s.Read(0xFF02), s.Read(0xFF01), s.interrupts.Flag)
+	}
+}
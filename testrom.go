@@ -0,0 +1,267 @@
+package gameboy
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/hmatheisen/gameboy/bus"
+)
+
+// ErrTestTimeout is returned when a test ROM runs out of frames before
+// reporting a result
+var ErrTestTimeout = errors.New("test ROM did not report a result")
+
+// TestReport is what a test ROM reported when it was done
+type TestReport struct {
+	Passed bool
+	// Output is the text the ROM printed on the serial port
+	Output string
+}
+
+// Blargg ROMs with cartridge RAM also report there, behind the signature
+// DE B0 61 at 0xA001: 0xA000 holds 0x80 while running, 0x81 when the ROM
+// waits for a reset, then the result code with 0 for success. The text
+// printed so far starts at 0xA004 and ends with a 0
+var blarggSignature = [3]uint8{0xDE, 0xB0, 0x61}
+
+const blarggRunning = 0x80
+
+// Returns the result reported in cartridge RAM, ok is false unless a ROM
+// reported one there
+func blarggMemoryReport(b bus.Bus) (report TestReport, ok bool) {
+	for i, value := range blarggSignature {
+		if b.Read(0xA001+uint16(i)) != value {
+			return TestReport{}, false
+		}
+	}
+
+	status := b.Read(0xA000)
+	if status >= blarggRunning {
+		return TestReport{}, false
+	}
+
+	var text []byte
+	for addr := uint16(0xA004); addr < 0xC000; addr++ {
+		c := b.Read(addr)
+		if c == 0 {
+			break
+		}
+		text = append(text, c)
+	}
+
+	return TestReport{Passed: status == 0, Output: string(text)}, true
+}
+
+// RunTestROM runs a test ROM until it reports a result, for at most the given
+// number of frames. Mooneye ROMs end on LD B,B with the Fibonacci numbers in
+// B, C, D, E, H and L when they pass, Blargg ROMs print Passed or Failed on
+// the serial port or leave a result code in cartridge RAM
+func RunTestROM(gb *Emulator, frames int) (TestReport, error) {
+	var output bytes.Buffer
+	if gb.Serial.Out != nil {
+		gb.Serial.Out = io.MultiWriter(gb.Serial.Out, &output)
+	} else {
+		gb.Serial.Out = &output
+	}
+
+	// Mooneye results are checked on LD B,B
+	breakpoint := false
+	previous := gb.CPU.OnBreakpoint
+	gb.CPU.OnBreakpoint = func() {
+		breakpoint = true
+		if previous != nil {
+			previous()
+		}
+	}
+	defer func() {
+		gb.CPU.OnBreakpoint = previous
+	}()
+
+	// A result in cartridge RAM only counts once the ROM marked itself
+	// running, it may otherwise come from a save of an earlier run
+	running := false
+
+	// Frames are counted in cycles, the LCD may well be off. Blargg results
+	// are looked for once a frame
+	end := gb.MCycles + frames*CyclesPerFrame
+	check := gb.MCycles
+	for gb.MCycles < end {
+		if gb.MCycles >= check {
+			check += CyclesPerFrame
+
+			if report, ok := blarggMemoryReport(gb.Bus); ok && running {
+				return report, nil
+			}
+
+			switch text := output.String(); {
+			case strings.Contains(text, "Passed"):
+				return TestReport{Passed: true, Output: text}, nil
+			case strings.Contains(text, "Failed"):
+				return TestReport{Passed: false, Output: text}, nil
+			}
+		}
+
+		if err := gb.step(); err != nil {
+			return TestReport{Output: output.String()}, err
+		}
+		if !running {
+			running = gb.Bus.Read(0xA000) == blarggRunning
+		}
+
+		if breakpoint {
+			breakpoint = false
+
+			r := gb.CPU
+			if r.B == 3 && r.C == 5 && r.D == 8 && r.E == 13 && r.H == 21 && r.L == 34 {
+				return TestReport{Passed: true, Output: output.String()}, nil
+			}
+			if r.B == 0x42 && r.C == 0x42 && r.D == 0x42 && r.E == 0x42 && r.H == 0x42 && r.L == 0x42 {
+				return TestReport{Passed: false, Output: output.String()}, nil
+			}
+		}
+	}
+
+	return TestReport{Output: output.String()}, ErrTestTimeout
+}
+
+// Suffixes of Mooneye ROM names standing for the models they are meant for.
+// The CGB and AGB ones have no model here
+var mooneyeModels = []struct {
+	suffix string
+	models []Model
+}{
+	{"dmg0", []Model{ModelDMG0}},
+	{"dmgABC", []Model{ModelDMG}},
+	{"mgb", []Model{ModelMGB}},
+	{"sgb2", []Model{ModelSGB2}},
+	{"sgb", []Model{ModelSGB}},
+	{"cgb0", nil},
+	{"cgbABCDE", nil},
+	{"cgb", nil},
+	{"agb", nil},
+	{"ags", nil},
+	{"G", []Model{ModelDMG0, ModelDMG, ModelMGB}},
+	{"S", []Model{ModelSGB, ModelSGB2}},
+	{"C", nil},
+	{"A", nil},
+}
+
+// MooneyeModels returns the models a Mooneye test ROM is meant for, going by
+// the suffix of its name: boot_regs-dmg0.gb is for the DMG0 only,
+// di_timing-GS.gb for the DMG and SGB families. ROMs without a suffix are
+// meant for every model
+func MooneyeModels(path string) []Model {
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+
+	i := strings.LastIndexByte(name, '-')
+	if i < 0 {
+		return slices.Clone(allModels)
+	}
+
+	var models []Model
+	for rest := name[i+1:]; rest != ""; {
+		found := false
+		for _, m := range mooneyeModels {
+			if strings.HasPrefix(rest, m.suffix) {
+				models = append(models, m.models...)
+				rest = rest[len(m.suffix):]
+				found = true
+				break
+			}
+		}
+		// A dash which is part of the name rather than a model suffix
+		if !found {
+			return slices.Clone(allModels)
+		}
+	}
+
+	var unique []Model
+	for _, model := range allModels {
+		if slices.Contains(models, model) {
+			unique = append(unique, model)
+		}
+	}
+	return unique
+}
+
+// TestMatrix collects the results of a suite of test ROMs on each model
+type TestMatrix struct {
+	roms    []string
+	results map[string]map[Model]bool
+}
+
+func NewTestMatrix() *TestMatrix {
+	m := new(TestMatrix)
+
+	m.results = make(map[string]map[Model]bool)
+
+	return m
+}
+
+func (m *TestMatrix) Add(rom string, model Model, passed bool) {
+	if m.results[rom] == nil {
+		m.roms = append(m.roms, rom)
+		m.results[rom] = make(map[Model]bool)
+	}
+	m.results[rom][model] = passed
+}
+
+// String lays out the results with a row per ROM and a column per model,
+// followed by how many passed on each model. Models a ROM is not run on are
+// marked with a dash
+func (m *TestMatrix) String() string {
+	width := len("passed")
+	for _, rom := range m.roms {
+		width = max(width, len(rom))
+	}
+
+	var b strings.Builder
+	row := func(name string, cells []string) {
+		line := fmt.Sprintf("%-*s", width, name)
+		for _, cell := range cells {
+			line += fmt.Sprintf("  %-7s", cell)
+		}
+		b.WriteString(strings.TrimRight(line, " ") + "\n")
+	}
+
+	var header []string
+	for _, model := range allModels {
+		header = append(header, model.String())
+	}
+	row("", header)
+
+	passed := make(map[Model]int)
+	run := make(map[Model]int)
+	for _, rom := range m.roms {
+		var cells []string
+		for _, model := range allModels {
+			result, ok := m.results[rom][model]
+			switch {
+			case !ok:
+				cells = append(cells, "-")
+			case result:
+				cells = append(cells, "pass")
+				passed[model]++
+			default:
+				cells = append(cells, "FAIL")
+			}
+			if ok {
+				run[model]++
+			}
+		}
+		row(rom, cells)
+	}
+
+	var totals []string
+	for _, model := range allModels {
+		totals = append(totals, fmt.Sprintf("%d/%d", passed[model], run[model]))
+	}
+	row("passed", totals)
+
+	return b.String()
+}
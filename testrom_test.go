@@ -0,0 +1,260 @@
+package gameboy
+
+import (
+	"errors"
+	"io/fs"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/hmatheisen/gameboy/bus"
+	"github.com/hmatheisen/gameboy/cartridge"
+)
+
+func TestRunTestROM(t *testing.T) {
+	tests := []struct {
+		name    string
+		program []uint8
+		text    string
+		passed  bool
+	}{
+		{
+			"Fibonacci",
+			[]uint8{0x06, 3, 0x0E, 5, 0x16, 8, 0x1E, 13, 0x26, 21, 0x2E, 34, 0x40, 0xC3, 0x0C, 0xC0},
+			"", true,
+		},
+		{
+			"0x42",
+			[]uint8{0x06, 0x42, 0x48, 0x50, 0x58, 0x60, 0x68, 0x40, 0xC3, 0x07, 0xC0},
+			"", false,
+		},
+		{
+			// LD HL, $C100; LD A, [HL+]; LDH [$01], A; LD A, $81;
+			// LDH [$02], A; JP back to the load
+			"serial passed",
+			[]uint8{0x21, 0x00, 0xC1, 0x2A, 0xE0, 0x01, 0x3E, 0x81, 0xE0, 0x02, 0xC3, 0x03, 0xC0},
+			"cpu_instrs\n\nPassed", true,
+		},
+		{
+			"serial failed",
+			[]uint8{0x21, 0x00, 0xC1, 0x2A, 0xE0, 0x01, 0x3E, 0x81, 0xE0, 0x02, 0xC3, 0x03, 0xC0},
+			"cpu_instrs\n\nFailed", false,
+		},
+	}
+
+	for _, tt := range tests {
+		gb := New()
+		gb.CPU.PC = 0xC000
+		loadProgram(gb, 0xC000, tt.program...)
+		loadProgram(gb, 0xC100, []uint8(tt.text)...)
+
+		report, err := RunTestROM(gb, 60)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if report.Passed != tt.passed {
+			t.Errorf("%s: want: passed %t; got %t", tt.name, tt.passed, report.Passed)
+		}
+		if !strings.HasPrefix(report.Output, tt.text) {
+			t.Errorf("%s: want: output %q; got %q", tt.name, tt.text, report.Output)
+		}
+	}
+
+	gb := New()
+	gb.CPU.PC = 0xC000
+	loadProgram(gb, 0xC000, 0xC3, 0x00, 0xC0)
+	if _, err := RunTestROM(gb, 2); !errors.Is(err, ErrTestTimeout) {
+		t.Errorf("want: %v; got %v", ErrTestTimeout, err)
+	}
+}
+
+func TestBlarggMemoryReport(t *testing.T) {
+	tests := []struct {
+		name   string
+		status uint8
+		passed bool
+	}{
+		{"passed", 0x00, true},
+		{"failed", 0x03, false},
+	}
+
+	for _, tt := range tests {
+		gb := New()
+		gb.Bus.Map(0xA000, 0xBFFF, bus.NewRAM(0xA000, 0x2000))
+		gb.CPU.PC = 0xC000
+		// LD A, $80; LD [$A000], A; LD A, status; LD [$A000], A; JP to itself
+		loadProgram(gb, 0xC000, 0x3E, blarggRunning, 0xEA, 0x00, 0xA0,
+			0x3E, tt.status, 0xEA, 0x00, 0xA0, 0xC3, 0x0A, 0xC0)
+		loadProgram(gb, 0xA001, 0xDE, 0xB0, 0x61)
+		loadProgram(gb, 0xA004, []uint8("mem_timing\n\n"+tt.name+"\n\x00")...)
+
+		report, err := RunTestROM(gb, 60)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if report.Passed != tt.passed {
+			t.Errorf("%s: want: passed %t; got %t", tt.name, tt.passed, report.Passed)
+		}
+		if want := "mem_timing\n\n" + tt.name + "\n"; report.Output != want {
+			t.Errorf("%s: want: output %q; got %q", tt.name, want, report.Output)
+		}
+	}
+
+	// Without the signature the status byte means nothing
+	gb := New()
+	gb.Bus.Map(0xA000, 0xBFFF, bus.NewRAM(0xA000, 0x2000))
+	gb.CPU.PC = 0xC000
+	loadProgram(gb, 0xC000, 0xC3, 0x00, 0xC0)
+	if _, err := RunTestROM(gb, 2); !errors.Is(err, ErrTestTimeout) {
+		t.Errorf("no signature: want: %v; got %v", ErrTestTimeout, err)
+	}
+
+	// A result restored from a save while the ROM never started
+	gb = New()
+	gb.Bus.Map(0xA000, 0xBFFF, bus.NewRAM(0xA000, 0x2000))
+	gb.CPU.PC = 0xC000
+	loadProgram(gb, 0xC000, 0xC3, 0x00, 0xC0)
+	loadProgram(gb, 0xA000, 0x00, 0xDE, 0xB0, 0x61)
+	loadProgram(gb, 0xA004, []uint8("mem_timing\n\nPassed\n\x00")...)
+	if _, err := RunTestROM(gb, 2); !errors.Is(err, ErrTestTimeout) {
+		t.Errorf("stale result: want: %v; got %v", ErrTestTimeout, err)
+	}
+}
+
+// cpu_instrs as a whole takes close to a minute
+const blarggFrames = 120 * 60
+
+// Runs every ROM under testdata/blargg, such as cpu_instrs, instr_timing,
+// mem_timing and dmg_sound, each as a subtest named after its path
+func TestBlargg(t *testing.T) {
+	dir := filepath.Join("testdata", "blargg")
+
+	var roms []string
+	filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err == nil && !d.IsDir() && filepath.Ext(path) == ".gb" {
+			roms = append(roms, path)
+		}
+		return nil
+	})
+	if len(roms) == 0 {
+		t.Skip("blargg tests not found in testdata")
+	}
+
+	for _, rom := range roms {
+		name, _ := filepath.Rel(dir, rom)
+
+		t.Run(filepath.ToSlash(name), func(t *testing.T) {
+			cart, err := cartridge.Load(rom, cartridge.WithoutSave())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			gb := New(WithSampleRate(0))
+			gb.InsertCartridge(cart)
+
+			report, err := RunTestROM(gb, blarggFrames)
+			if err != nil {
+				t.Fatalf("%v\n%s", err, report.Output)
+			}
+			if !report.Passed {
+				t.Errorf("failed:\n%s", report.Output)
+			}
+		})
+	}
+}
+
+func TestMooneyeModels(t *testing.T) {
+	tests := []struct {
+		rom    string
+		models []Model
+	}{
+		{"acceptance/add_sp_e_timing.gb", allModels},
+		{"acceptance/boot_regs-dmg0.gb", []Model{ModelDMG0}},
+		{"acceptance/boot_regs-dmgABC.gb", []Model{ModelDMG}},
+		{"acceptance/boot_hwio-dmgABCmgb.gb", []Model{ModelDMG, ModelMGB}},
+		{"acceptance/boot_regs-sgb2.gb", []Model{ModelSGB2}},
+		{"acceptance/boot_div-S.gb", []Model{ModelSGB, ModelSGB2}},
+		{"acceptance/di_timing-GS.gb", allModels},
+		{"acceptance/boot_regs-cgb.gb", nil},
+		{"misc/boot_div-A.gb", nil},
+		{"emulator-only/mbc1/rom_512kb.gb", allModels},
+	}
+
+	for _, tt := range tests {
+		if got := MooneyeModels(tt.rom); !slices.Equal(got, tt.models) {
+			t.Errorf("%s: want: %v; got %v", tt.rom, tt.models, got)
+		}
+	}
+}
+
+func TestTestMatrix(t *testing.T) {
+	m := NewTestMatrix()
+	m.Add("boot_regs-dmg0.gb", ModelDMG0, false)
+	m.Add("di_timing-GS.gb", ModelDMG0, true)
+	m.Add("di_timing-GS.gb", ModelDMG, true)
+	m.Add("di_timing-GS.gb", ModelMGB, true)
+	m.Add("di_timing-GS.gb", ModelSGB, false)
+	m.Add("di_timing-GS.gb", ModelSGB2, true)
+
+	want := `                   dmg0     dmg      mgb      sgb      sgb2
+boot_regs-dmg0.gb  FAIL     -        -        -        -
+di_timing-GS.gb    pass     pass     pass     FAIL     pass
+passed             1/2      1/1      1/1      0/1      1/1
+`
+	if got := m.String(); got != want {
+		t.Errorf("want:\n%s\ngot:\n%s", want, got)
+	}
+}
+
+// Runs every ROM under testdata/mooneye on each model it is meant for, as
+// subtests named after the model and the path of the ROM, and logs the
+// results as a matrix. The manual-only ROMs report nothing on their own
+func TestMooneye(t *testing.T) {
+	dir := filepath.Join("testdata", "mooneye")
+
+	var roms []string
+	filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err == nil && d.IsDir() && (d.Name() == "manual-only" || d.Name() == "utils") {
+			return filepath.SkipDir
+		}
+		if err == nil && !d.IsDir() && filepath.Ext(path) == ".gb" {
+			roms = append(roms, path)
+		}
+		return nil
+	})
+	if len(roms) == 0 {
+		t.Skip("mooneye tests not found in testdata")
+	}
+
+	matrix := NewTestMatrix()
+	for _, rom := range roms {
+		name, _ := filepath.Rel(dir, rom)
+		name = filepath.ToSlash(name)
+
+		for _, model := range MooneyeModels(rom) {
+			passed := t.Run(model.String()+"/"+name, func(t *testing.T) {
+				cart, err := cartridge.Load(rom, cartridge.WithoutSave())
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				gb := New(WithModel(model), WithSampleRate(0))
+				gb.InsertCartridge(cart)
+
+				report, err := RunTestROM(gb, 600)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !report.Passed {
+					t.Error("failed")
+				}
+			})
+			matrix.Add(name, model, passed)
+		}
+	}
+
+	t.Logf("\n%s", matrix)
+}
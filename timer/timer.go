@@ -0,0 +1,112 @@
+// Package timer emulates the divider and the programmable timer
+package timer
+
+import "github.com/hmatheisen/gameboy/interrupt"
+
+// Bit of the internal divider whose falling edge increments TIMA, for each
+// TAC clock select value
+var timerBits = [4]uint16{9, 3, 5, 7}
+
+const tacEnable = 1 << 2
+
+// Timer holds DIV (0xFF04), TIMA (0xFF05), TMA (0xFF06) and TAC (0xFF07).
+// DIV is the upper byte of a 16-bit divider counting T-cycles, and TIMA
+// counts the falling edges of one of its bits, which is why writing DIV or
+// TAC can increment TIMA
+type Timer struct {
+	TIMA uint8
+	TMA  uint8
+	TAC  uint8
+
+	div uint16
+
+	// TIMA reads 0 for a cycle after overflowing, then gets reloaded from
+	// TMA and requests the interrupt. During the reload cycle writes to TIMA
+	// are ignored and writes to TMA go through to TIMA
+	overflow  bool
+	reloading bool
+
+	interrupts *interrupt.Interrupts
+}
+
+func New(interrupts *interrupt.Interrupts) *Timer {
+	timer := new(Timer)
+
+	timer.interrupts = interrupts
+	// State left by the DMG boot ROM
+	timer.div = 0xABCC
+	timer.TAC = 0xF8
+
+	return timer
+}
+
+// Tick advances the timer by one machine cycle
+func (t *Timer) Tick() {
+	t.reloading = false
+	if t.overflow {
+		t.overflow = false
+		t.reloading = true
+		t.TIMA = t.TMA
+		t.interrupts.Request(interrupt.Timer)
+	}
+
+	t.SetDivider(t.div + 4)
+}
+
+// Whether the divider bit selected by TAC is set, gated by the enable bit
+func (t *Timer) signal() bool {
+	return t.TAC&tacEnable != 0 && t.div>>timerBits[t.TAC&0x03]&1 != 0
+}
+
+// SetDivider sets the internal divider, of which DIV is the upper byte. Like
+// any change of the divider, it may increment TIMA
+func (t *Timer) SetDivider(value uint16) {
+	before := t.signal()
+	t.div = value
+	if before && !t.signal() {
+		t.increment()
+	}
+}
+
+func (t *Timer) increment() {
+	t.TIMA++
+	if t.TIMA == 0 {
+		t.overflow = true
+	}
+}
+
+func (t *Timer) Read(addr uint16) uint8 {
+	switch addr {
+	case 0xFF04:
+		return uint8(t.div >> 8)
+	case 0xFF05:
+		return t.TIMA
+	case 0xFF06:
+		return t.TMA
+	default:
+		return t.TAC | 0xF8
+	}
+}
+
+func (t *Timer) Write(addr uint16, value uint8) {
+	switch addr {
+	case 0xFF04:
+		t.SetDivider(0)
+	case 0xFF05:
+		if !t.reloading {
+			t.TIMA = value
+			t.overflow = false
+		}
+	case 0xFF06:
+		t.TMA = value
+		if t.reloading {
+			t.TIMA = value
+		}
+	default:
+		before := t.signal()
+		t.TAC = value | 0xF8
+		if before && !t.signal() {
+			t.increment()
+		}
+	}
+}
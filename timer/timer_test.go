@@ -0,0 +1,167 @@
+package timer
+
+import (
+	"testing"
+
+	"github.com/hmatheisen/gameboy/interrupt"
+)
+
+func newTestTimer() *Timer {
+	timer := New(interrupt.New())
+	timer.interrupts.Flag = 0x00
+	timer.div = 0
+
+	return timer
+}
+
+func tickTimer(t *Timer, cycles int) {
+	for i := 0; i < cycles; i++ {
+		t.Tick()
+	}
+}
+
+func TestTimerFrequencies(t *testing.T) {
+	tests := []struct {
+		tac    uint8
+		cycles int
+	}{
+		{0x04, 256},
+		{0x05, 4},
+		{0x06, 16},
+		{0x07, 64},
+	}
+
+	for _, tt := range tests {
+		timer := newTestTimer()
+		timer.Write(0xFF07, tt.tac)
+
+		tickTimer(timer, tt.cycles-1)
+		if timer.TIMA != 0 {
+			t.Errorf("TAC %02x: want: TIMA = 0 after %d cycles; got %d", tt.tac, tt.cycles-1, timer.TIMA)
+		}
+		tickTimer(timer, 1)
+		if timer.TIMA != 1 {
+			t.Errorf("TAC %02x: want: TIMA = 1 after %d cycles; got %d", tt.tac, tt.cycles, timer.TIMA)
+		}
+	}
+}
+
+func TestTimerDisabled(t *testing.T) {
+	timer := newTestTimer()
+	timer.Write(0xFF07, 0x01)
+	tickTimer(timer, 1000)
+
+	if timer.TIMA != 0 {
+		t.Errorf("want: TIMA = 0; got %d", timer.TIMA)
+	}
+	if timer.Read(0xFF07) != 0xF9 {
+		t.Errorf("want: TAC = f9; got %02x", timer.Read(0xFF07))
+	}
+}
+
+func TestTimerDIV(t *testing.T) {
+	timer := newTestTimer()
+	tickTimer(timer, 64)
+	if timer.Read(0xFF04) != 1 {
+		t.Errorf("want: DIV = 1; got %d", timer.Read(0xFF04))
+	}
+
+	timer.Write(0xFF04, 0x42)
+	if timer.Read(0xFF04) != 0 {
+		t.Errorf("want: DIV reset by writes; got %d", timer.Read(0xFF04))
+	}
+}
+
+func TestTimerOverflow(t *testing.T) {
+	timer := newTestTimer()
+	timer.Write(0xFF07, 0x05)
+	timer.TMA = 0x80
+	timer.TIMA = 0xFF
+
+	tickTimer(timer, 4)
+	if timer.Read(0xFF05) != 0 {
+		t.Errorf("want: TIMA = 0 for a cycle after overflow; got %02x", timer.Read(0xFF05))
+	}
+	if timer.interrupts.Flag != 0 {
+		t.Errorf("want: no interrupt yet; got IF = %02x", timer.interrupts.Flag)
+	}
+
+	tickTimer(timer, 1)
+	if timer.Read(0xFF05) != 0x80 {
+		t.Errorf("want: TIMA reloaded to 80; got %02x", timer.Read(0xFF05))
+	}
+	if timer.interrupts.Flag != uint8(interrupt.Timer) {
+		t.Errorf("want: timer interrupt; got IF = %02x", timer.interrupts.Flag)
+	}
+}
+
+func TestTimerReloadWrites(t *testing.T) {
+	overflow := func() *Timer {
+		timer := newTestTimer()
+		timer.Write(0xFF07, 0x05)
+		timer.TMA = 0x80
+		timer.TIMA = 0xFF
+		tickTimer(timer, 4)
+		return timer
+	}
+
+	// Writing TIMA in the cycle after the overflow cancels the reload
+	timer := overflow()
+	timer.Write(0xFF05, 0x10)
+	tickTimer(timer, 1)
+	if timer.TIMA != 0x10 || timer.interrupts.Flag != 0 {
+		t.Errorf("cancelled: want: TIMA = 10, IF = 0; got TIMA = %02x, IF = %02x", timer.TIMA, timer.interrupts.Flag)
+	}
+
+	// Writing TIMA while it is reloaded is ignored
+	timer = overflow()
+	tickTimer(timer, 1)
+	timer.Write(0xFF05, 0x10)
+	if timer.TIMA != 0x80 {
+		t.Errorf("ignored: want: TIMA = 80; got %02x", timer.TIMA)
+	}
+
+	// Writing TMA while TIMA is reloaded goes through to TIMA
+	timer = overflow()
+	tickTimer(timer, 1)
+	timer.Write(0xFF06, 0x20)
+	if timer.TIMA != 0x20 {
+		t.Errorf("TMA: want: TIMA = 20; got %02x", timer.TIMA)
+	}
+}
+
+func TestTimerDIVWriteGlitch(t *testing.T) {
+	timer := newTestTimer()
+	timer.Write(0xFF07, 0x05)
+	// Bit 3 of the divider is set after 2 cycles
+	tickTimer(timer, 2)
+
+	timer.Write(0xFF04, 0)
+	if timer.TIMA != 1 {
+		t.Errorf("want: TIMA incremented by the DIV reset; got %d", timer.TIMA)
+	}
+}
+
+func TestTimerTACWriteGlitch(t *testing.T) {
+	tests := []struct {
+		name string
+		tac  uint8
+		tima uint8
+	}{
+		{"disabled", 0x01, 1},
+		{"selected bit low", 0x06, 1},
+		{"selected bit high", 0x04 | 0x03, 0},
+	}
+
+	for _, tt := range tests {
+		timer := newTestTimer()
+		timer.Write(0xFF07, 0x05)
+		// Bits 3 and 7 of the divider are set, bits 5 and 9 are not
+		timer.div = 0x88
+
+		timer.Write(0xFF07, tt.tac)
+		if timer.TIMA != tt.tima {
+			t.Errorf("%s: want: TIMA = %d; got %d", tt.name, tt.tima, timer.TIMA)
+		}
+	}
+}
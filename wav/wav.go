@@ -0,0 +1,130 @@
+// Package wav writes 16-bit PCM WAV files
+package wav
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"os"
+)
+
+// HeaderSize is the size of the canonical WAV header preceding the samples
+const HeaderSize = 44
+
+// Writer writes interleaved float32 samples as a 16-bit PCM WAV file.
+// The sizes in the header are only known once every sample is written, so
+// they get filled in by Close
+type Writer struct {
+	w          io.WriteSeeker
+	sampleRate int
+	channels   int
+	dataSize   int
+	buf        []byte
+}
+
+func NewWriter(w io.WriteSeeker, sampleRate, channels int) (*Writer, error) {
+	wav := new(Writer)
+
+	wav.w = w
+	wav.sampleRate = sampleRate
+	wav.channels = channels
+
+	if err := wav.writeHeader(); err != nil {
+		return nil, err
+	}
+
+	return wav, nil
+}
+
+func (w *Writer) writeHeader() error {
+	blockAlign := w.channels * 2
+
+	var header [HeaderSize]byte
+	copy(header[0:], "RIFF")
+	binary.LittleEndian.PutUint32(header[4:], uint32(36+w.dataSize))
+	copy(header[8:], "WAVEfmt ")
+	binary.LittleEndian.PutUint32(header[16:], 16)
+	binary.LittleEndian.PutUint16(header[20:], 1) // PCM
+	binary.LittleEndian.PutUint16(header[22:], uint16(w.channels))
+	binary.LittleEndian.PutUint32(header[24:], uint32(w.sampleRate))
+	binary.LittleEndian.PutUint32(header[28:], uint32(w.sampleRate*blockAlign))
+	binary.LittleEndian.PutUint16(header[32:], uint16(blockAlign))
+	binary.LittleEndian.PutUint16(header[34:], 16)
+	copy(header[36:], "data")
+	binary.LittleEndian.PutUint32(header[40:], uint32(w.dataSize))
+
+	_, err := w.w.Write(header[:])
+	return err
+}
+
+// WriteSamples converts samples in the -1..1 range to 16-bit, clipping
+// anything outside of it
+func (w *Writer) WriteSamples(samples []float32) error {
+	if len(samples)%w.channels != 0 {
+		return errors.New("wav: samples do not fill whole frames")
+	}
+
+	w.buf = w.buf[:0]
+	for _, sample := range samples {
+		value := int16(math.Round(float64(max(-1, min(sample, 1))) * math.MaxInt16))
+		w.buf = binary.LittleEndian.AppendUint16(w.buf, uint16(value))
+	}
+
+	n, err := w.w.Write(w.buf)
+	w.dataSize += n
+	return err
+}
+
+// DataSize returns the number of bytes of samples written so far
+func (w *Writer) DataSize() int {
+	return w.dataSize
+}
+
+// Close fills in the header, it does not close the underlying writer
+func (w *Writer) Close() error {
+	if _, err := w.w.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	if err := w.writeHeader(); err != nil {
+		return err
+	}
+	_, err := w.w.Seek(0, io.SeekEnd)
+	return err
+}
+
+// File is a Writer writing to a file it owns
+type File struct {
+	*Writer
+	file *os.File
+}
+
+func Create(path string, sampleRate, channels int) (*File, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+
+	w, err := NewWriter(file, sampleRate, channels)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return &File{Writer: w, file: file}, nil
+}
+
+// Close fills in the header and closes the file, closing it again does
+// nothing
+func (f *File) Close() error {
+	if f.file == nil {
+		return nil
+	}
+
+	err := f.Writer.Close()
+	if closeErr := f.file.Close(); err == nil {
+		err = closeErr
+	}
+	f.file = nil
+	return err
+}
@@ -0,0 +1,70 @@
+package wav
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWAVWriter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.wav")
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	w, err := NewWriter(file, 44100, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.WriteSamples([]float32{0, 1, -1, 0.5}); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.WriteSamples([]float32{2, -2}); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.WriteSamples([]float32{0}); err == nil {
+		t.Errorf("want: error for a partial frame")
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) != HeaderSize+12 {
+		t.Fatalf("want: %d bytes; got %d", HeaderSize+12, len(data))
+	}
+
+	header := []struct {
+		name  string
+		got   uint32
+		value uint32
+	}{
+		{"RIFF size", binary.LittleEndian.Uint32(data[4:]), 36 + 12},
+		{"channels", uint32(binary.LittleEndian.Uint16(data[22:])), 2},
+		{"sample rate", binary.LittleEndian.Uint32(data[24:]), 44100},
+		{"byte rate", binary.LittleEndian.Uint32(data[28:]), 44100 * 4},
+		{"bits per sample", uint32(binary.LittleEndian.Uint16(data[34:])), 16},
+		{"data size", binary.LittleEndian.Uint32(data[40:]), 12},
+	}
+	for _, h := range header {
+		if h.got != h.value {
+			t.Errorf("%s: want: %d; got %d", h.name, h.value, h.got)
+		}
+	}
+	if string(data[0:4]) != "RIFF" || string(data[8:16]) != "WAVEfmt " || string(data[36:40]) != "data" {
+		t.Errorf("want: RIFF, WAVEfmt and data tags; got %q", data[:HeaderSize])
+	}
+
+	want := []int16{0, 32767, -32767, 16384, 32767, -32767}
+	for i, w := range want {
+		if got := int16(binary.LittleEndian.Uint16(data[HeaderSize+i*2:])); got != w {
+			t.Errorf("sample %d: want: %d; got %d", i, w, got)
+		}
+	}
+}